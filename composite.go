@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+// compositeNullField returns the name of the field that flags a composite
+// type struct as being NULL, one that does not collide with the fields
+// for the attributes of the type
+func compositeNullField(f m.PgUsertypeMetadata) string {
+
+	name := "Null"
+	for {
+		taken := false
+		for _, col := range f.Columns {
+			if u.ToUpperCamelCase(col.ColumnName) == name {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return name
+		}
+		name = "Is" + name
+	}
+}
+
+// genCompositeMethods generates the sql.Scanner and driver.Valuer
+// implementations for a composite type struct, and the array type for
+// the struct. These use the text representation of row values.
+func genCompositeMethods(args cArgs, f m.PgUsertypeMetadata, cb *u.LineBuf) {
+
+	rn := receiverName(f.StructName)
+	desc := fmt.Sprintf("%s.%s", f.SchemaName, f.ObjName)
+	nullField := compositeNullField(f)
+
+	cb.Append(fmt.Sprintf("// Scan implements the sql.Scanner interface for the %s from the text", f.StructName))
+	cb.Append(fmt.Sprintf("// representation of the %s type. A NULL scans as the %s flag being set.", desc, nullField))
+	cb.Append(fmt.Sprintf("func (%s *%s) Scan(src interface{}) error {", rn, f.StructName))
+	cb.Append("")
+	cb.Append("\tif src == nil {")
+	cb.Append(fmt.Sprintf("\t\t*%s = %s{%s: true}", rn, f.StructName, nullField))
+	cb.Append("\t\treturn nil")
+	cb.Append("\t}")
+	cb.Append("")
+	cb.Append(fmt.Sprintf("\tfields, err := scanRecord(src, %d)", len(f.Columns)))
+	cb.Append("\tif err != nil {")
+	cb.Append("\t\treturn err")
+	cb.Append("\t}")
+	cb.Append(fmt.Sprintf("\t%s.%s = false", rn, nullField))
+	for i, col := range f.Columns {
+		cb.Append("")
+		cb.Append(fmt.Sprintf("\terr = scanText(&%s.%s, fields[%d])", rn, u.ToUpperCamelCase(col.ColumnName), i))
+		cb.Append("\tif err != nil {")
+		cb.Append(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s.%s: %%w\", err)", desc, col.ColumnName))
+		cb.Append("\t}")
+	}
+	cb.Append("\treturn nil")
+	cb.Append("}")
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// Value implements the driver.Valuer interface for the %s as the text", f.StructName))
+	cb.Append(fmt.Sprintf("// representation of the %s type, or as NULL when the %s flag is set", desc, nullField))
+	cb.Append(fmt.Sprintf("func (%s %s) Value() (driver.Value, error) {", rn, f.StructName))
+	cb.Append(fmt.Sprintf("\tif %s.%s {", rn, nullField))
+	cb.Append("\t\treturn nil, nil")
+	cb.Append("\t}")
+	cb.Append("\treturn valueRecord(")
+	for _, col := range f.Columns {
+		cb.Append(fmt.Sprintf("\t\t%s.%s,", rn, u.ToUpperCamelCase(col.ColumnName)))
+	}
+	cb.Append("\t)")
+	cb.Append("}")
+	cb.Append("")
+
+	arrayName := f.StructName + "Array"
+
+	cb.Append(fmt.Sprintf("// %s is an array of the %s type", arrayName, desc))
+	cb.Append(fmt.Sprintf("type %s []%s", arrayName, f.StructName))
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// Scan implements the sql.Scanner interface for the %s", arrayName))
+	cb.Append(fmt.Sprintf("func (%s *%s) Scan(src interface{}) error {", rn, arrayName))
+	cb.Append("")
+	cb.Append("\telems, err := scanArray(src)")
+	cb.Append("\tif err != nil || elems == nil {")
+	cb.Append(fmt.Sprintf("\t\t*%s = nil", rn))
+	cb.Append("\t\treturn err")
+	cb.Append("\t}")
+	cb.Append("")
+	cb.Append(fmt.Sprintf("\td := make(%s, len(elems))", arrayName))
+	cb.Append("\tfor i, elem := range elems {")
+	cb.Append("\t\terr = scanText(&d[i], elem)")
+	cb.Append("\t\tif err != nil {")
+	cb.Append("\t\t\treturn err")
+	cb.Append("\t\t}")
+	cb.Append("\t}")
+	cb.Append(fmt.Sprintf("\t*%s = d", rn))
+	cb.Append("\treturn nil")
+	cb.Append("}")
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// Value implements the driver.Valuer interface for the %s", arrayName))
+	cb.Append(fmt.Sprintf("func (%s %s) Value() (driver.Value, error) {", rn, arrayName))
+	cb.Append(fmt.Sprintf("\tif %s == nil {", rn))
+	cb.Append("\t\treturn nil, nil")
+	cb.Append("\t}")
+	cb.Append(fmt.Sprintf("\telems := make([]interface{}, len(%s))", rn))
+	cb.Append(fmt.Sprintf("\tfor i := range %s {", rn))
+	cb.Append(fmt.Sprintf("\t\telems[i] = %s[i]", rn))
+	cb.Append("\t}")
+	cb.Append("\treturn valueArray(elems...)")
+	cb.Append("}")
+	cb.Append("")
+}
+
+// genCompositeSupportCode generates the functions used by the generated
+// composite type Scan and Value methods for parsing and formatting the
+// text representation of row and array values
+func genCompositeSupportCode(args cArgs) {
+
+	cb := u.NewLineBuf()
+
+	for _, s := range compositeSupport {
+		cb.Append(s)
+	}
+
+	writeCode(args, "compositesupport", "", cb)
+}
+
+var compositeSupport = []string{
+	`// scanRecord returns the fields of a composite (row) value. A NULL`,
+	`// value returns n NULL fields.`,
+	`func scanRecord(src interface{}, n int) ([]*string, error) {`,
+	``,
+	`	var s string`,
+	`	switch v := src.(type) {`,
+	`	case nil:`,
+	`		return make([]*string, n), nil`,
+	`	case string:`,
+	`		s = v`,
+	`	case []byte:`,
+	`		s = string(v)`,
+	`	default:`,
+	`		return nil, fmt.Errorf("cannot scan %T as a record", src)`,
+	`	}`,
+	``,
+	`	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {`,
+	`		return nil, fmt.Errorf("invalid record %q", s)`,
+	`	}`,
+	``,
+	`	fields := parseElements(s[1:len(s)-1], false)`,
+	`	if len(fields) != n {`,
+	`		return nil, fmt.Errorf("expected %d fields in record %q, got %d", n, s, len(fields))`,
+	`	}`,
+	`	return fields, nil`,
+	`}`,
+	``,
+	`// scanArray returns the elements of a one-dimensional array value. A`,
+	`// NULL value returns a nil slice.`,
+	`func scanArray(src interface{}) ([]*string, error) {`,
+	``,
+	`	var s string`,
+	`	switch v := src.(type) {`,
+	`	case nil:`,
+	`		return nil, nil`,
+	`	case string:`,
+	`		s = v`,
+	`	case []byte:`,
+	`		s = string(v)`,
+	`	default:`,
+	`		return nil, fmt.Errorf("cannot scan %T as an array", src)`,
+	`	}`,
+	``,
+	`	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {`,
+	`		return nil, fmt.Errorf("invalid array %q", s)`,
+	`	}`,
+	`	if s == "{}" {`,
+	`		return []*string{}, nil`,
+	`	}`,
+	`	return parseElements(s[1:len(s)-1], true), nil`,
+	`}`,
+	``,
+	`// parseElements splits the comma-separated, optionally quoted, elements`,
+	`// of a record or array value. Unquoted empty record fields, and unquoted`,
+	`// NULL array elements, are returned as nil.`,
+	`func parseElements(s string, isArray bool) (elems []*string) {`,
+	``,
+	`	var b strings.Builder`,
+	`	quoted := false`,
+	`	inQuotes := false`,
+	``,
+	`	appendElem := func() {`,
+	`		v := b.String()`,
+	`		switch {`,
+	`		case quoted:`,
+	`			elems = append(elems, &v)`,
+	`		case isArray && strings.EqualFold(v, "NULL"):`,
+	`			elems = append(elems, nil)`,
+	`		case !isArray && v == "":`,
+	`			elems = append(elems, nil)`,
+	`		default:`,
+	`			elems = append(elems, &v)`,
+	`		}`,
+	`		b.Reset()`,
+	`		quoted = false`,
+	`	}`,
+	``,
+	`	for i := 0; i < len(s); i++ {`,
+	`		c := s[i]`,
+	`		switch {`,
+	`		case c == '\\' && i+1 < len(s):`,
+	`			i++`,
+	`			b.WriteByte(s[i])`,
+	`		case inQuotes && c == '"' && i+1 < len(s) && s[i+1] == '"':`,
+	`			i++`,
+	`			b.WriteByte('"')`,
+	`		case c == '"':`,
+	`			inQuotes = !inQuotes`,
+	`			quoted = true`,
+	`		case !inQuotes && c == ',':`,
+	`			appendElem()`,
+	`		default:`,
+	`			b.WriteByte(c)`,
+	`		}`,
+	`	}`,
+	`	appendElem()`,
+	`	return elems`,
+	`}`,
+	``,
+	`// valueRecord returns the text representation of a composite (row) value`,
+	`func valueRecord(fields ...interface{}) (driver.Value, error) {`,
+	``,
+	`	var ary []string`,
+	`	for _, f := range fields {`,
+	`		s, err := valueText(f)`,
+	`		if err != nil {`,
+	`			return nil, err`,
+	`		}`,
+	`		if s == nil {`,
+	`			ary = append(ary, "")`,
+	`		} else {`,
+	`			ary = append(ary, quoteElement(*s))`,
+	`		}`,
+	`	}`,
+	`	return "(" + strings.Join(ary, ",") + ")", nil`,
+	`}`,
+	``,
+	`// valueArray returns the text representation of a one-dimensional array value`,
+	`func valueArray(elems ...interface{}) (driver.Value, error) {`,
+	``,
+	`	var ary []string`,
+	`	for _, e := range elems {`,
+	`		s, err := valueText(e)`,
+	`		if err != nil {`,
+	`			return nil, err`,
+	`		}`,
+	`		if s == nil {`,
+	`			ary = append(ary, "NULL")`,
+	`		} else {`,
+	`			ary = append(ary, quoteElement(*s))`,
+	`		}`,
+	`	}`,
+	`	return "{" + strings.Join(ary, ",") + "}", nil`,
+	`}`,
+	``,
+	`// quoteElement quotes a record field or array element`,
+	`func quoteElement(s string) string {`,
+	`	s = strings.ReplaceAll(s, "\\", "\\\\")`,
+	`	s = strings.ReplaceAll(s, "\"", "\\\"")`,
+	`	return "\"" + s + "\""`,
+	`}`,
+	``,
+	`// valueText returns the text representation of a value, or nil for NULL`,
+	`func valueText(v interface{}) (*string, error) {`,
+	``,
+	`	dv, err := driver.DefaultParameterConverter.ConvertValue(v)`,
+	`	if err != nil {`,
+	`		return nil, err`,
+	`	}`,
+	``,
+	`	var s string`,
+	`	switch x := dv.(type) {`,
+	`	case nil:`,
+	`		return nil, nil`,
+	`	case string:`,
+	`		s = x`,
+	`	case []byte:`,
+	`		s = "\\x" + hex.EncodeToString(x)`,
+	`	case int64:`,
+	`		s = strconv.FormatInt(x, 10)`,
+	`	case float64:`,
+	`		s = strconv.FormatFloat(x, 'g', -1, 64)`,
+	`	case bool:`,
+	`		s = strconv.FormatBool(x)`,
+	`	case time.Time:`,
+	`		s = x.Format("2006-01-02 15:04:05.999999999Z07:00")`,
+	`	default:`,
+	`		s = fmt.Sprint(x)`,
+	`	}`,
+	`	return &s, nil`,
+	`}`,
+	``,
+	`// scanText scans the text representation of a value into dest. The`,
+	`// destination is either a sql.Scanner or a pointer to a basic Go type.`,
+	`func scanText(dest interface{}, src *string) error {`,
+	``,
+	`	if s, ok := dest.(sql.Scanner); ok {`,
+	`		if src == nil {`,
+	`			return s.Scan(nil)`,
+	`		}`,
+	`		return s.Scan(*src)`,
+	`	}`,
+	``,
+	`	rv := reflect.ValueOf(dest).Elem()`,
+	`	if src == nil {`,
+	`		rv.Set(reflect.Zero(rv.Type()))`,
+	`		return nil`,
+	`	}`,
+	``,
+	`	if t, ok := dest.(*time.Time); ok {`,
+	`		for _, layout := range []string{"2006-01-02 15:04:05.999999999Z07", "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999", "2006-01-02", "15:04:05.999999999"} {`,
+	`			v, err := time.Parse(layout, *src)`,
+	`			if err == nil {`,
+	`				*t = v`,
+	`				return nil`,
+	`			}`,
+	`		}`,
+	`		return fmt.Errorf("cannot parse %q as a time", *src)`,
+	`	}`,
+	``,
+	`	switch rv.Kind() {`,
+	`	case reflect.Ptr:`,
+	`		p := reflect.New(rv.Type().Elem())`,
+	`		err := scanText(p.Interface(), src)`,
+	`		if err != nil {`,
+	`			return err`,
+	`		}`,
+	`		rv.Set(p)`,
+	`	case reflect.String:`,
+	`		rv.SetString(*src)`,
+	`	case reflect.Bool:`,
+	`		rv.SetBool(*src == "t" || *src == "true")`,
+	`	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:`,
+	`		n, err := strconv.ParseInt(*src, 10, 64)`,
+	`		if err != nil {`,
+	`			return err`,
+	`		}`,
+	`		rv.SetInt(n)`,
+	`	case reflect.Float32, reflect.Float64:`,
+	`		n, err := strconv.ParseFloat(*src, 64)`,
+	`		if err != nil {`,
+	`			return err`,
+	`		}`,
+	`		rv.SetFloat(n)`,
+	`	case reflect.Slice:`,
+	`		if rv.Type().Elem().Kind() != reflect.Uint8 {`,
+	`			return fmt.Errorf("cannot scan %q into %T", *src, dest)`,
+	`		}`,
+	`		b, err := hex.DecodeString(strings.TrimPrefix(*src, "\\x"))`,
+	`		if err != nil {`,
+	`			return err`,
+	`		}`,
+	`		rv.SetBytes(b)`,
+	`	default:`,
+	`		return fmt.Errorf("cannot scan %q into %T", *src, dest)`,
+	`	}`,
+	`	return nil`,
+	`}`,
+	``,
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+// TestCompositeSupport runs the tests in testdata/compositesupport
+// against the generated composite support code, as the code is only
+// generated as text by genCompositeSupportCode. The structs for the
+// composite types in the composites fixture are generated along with
+// it, using the plain Go types (so that the nested composite is a struct
+// value rather than a pointer), for the round trip tests.
+func TestCompositeSupport(t *testing.T) {
+
+	code := strings.Join(compositeSupport, "\n") + "\n" + compositeTypesCode(t)
+	runSupportTests(t, "compositesupport", code)
+}
+
+// runSupportTests runs the tests in the testdata directory against the
+// generated support code, in a module of its own
+func runSupportTests(t *testing.T, name, code string) {
+
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+
+	dir := t.TempDir()
+
+	imports, err := usedImports(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "package model\n\nimport (\n"
+	for _, p := range imports {
+		src += fmt.Sprintf("\t%q\n", p)
+	}
+	src += ")\n\n" + code + "\n"
+
+	files := map[string]string{
+		"go.mod":     "module model\n\ngo 1.18\n",
+		name + ".go": src,
+	}
+	test, err := os.ReadFile(filepath.Join("testdata", name, "support_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["support_test.go"] = string(test)
+
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goCmd, "test", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s tests failed: %s\n%s", name, err, out)
+	}
+}
+
+// compositeTypesCode returns the generated structs and methods for the
+// composite types in the composites fixture
+func compositeTypesCode(t *testing.T) string {
+
+	snap, err := m.ReadSnapshot(filepath.Join("testdata", "fixtures", "composites.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.SetNullStyle(m.NullStyleNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.SetNullStyle(m.NullStylePgtype)
+
+	md, err := m.GetSnapshot(m.NewMemCatalog(snap), snap.Schema, snap.Objects, snap.AppUser, snap.PgVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := u.NewLineBuf()
+	for _, f := range md.Types {
+		err = genTypeStruct(cArgs{}, f, cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		genCompositeMethods(cArgs{}, f, cb)
+	}
+	return cb.String()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	m "github.com/gsiems/pg2go/meta"
+)
+
+// config is the optional configuration file for the generator
+type config struct {
+	// Types overrides the Go type for Postgresql type names
+	Types map[string]m.TypeOverride `json:"types"`
+	// Columns overrides the Go type for specific columns, keyed by
+	// schema.table.column
+	Columns map[string]m.TypeOverride `json:"columns"`
+	// Functions sets the Go name for functions, keyed by
+	// schema.function(argument types) or schema.function
+	Functions map[string]string `json:"functions"`
+	// Cursors sets the row types of the refcursors returned by
+	// functions, keyed as for Functions
+	Cursors map[string][]string `json:"cursors"`
+}
+
+// loadConfig reads the JSON configuration file
+func loadConfig(fileName string) (c config, err error) {
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &c)
+	return
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// connEnvVars maps the Postgresql environment variables to the
+// connection parameters that they supply
+var connEnvVars = map[string]string{
+	"PGHOST":            "host",
+	"PGPORT":            "port",
+	"PGDATABASE":        "dbname",
+	"PGUSER":            "user",
+	"PGPASSWORD":        "password",
+	"PGSSLMODE":         "sslmode",
+	"PGSSLROOTCERT":     "sslrootcert",
+	"PGSSLCERT":         "sslcert",
+	"PGSSLKEY":          "sslkey",
+	"PGCONNECT_TIMEOUT": "connect_timeout",
+	"PGAPPNAME":         "application_name",
+}
+
+// connParams resolves the connection parameters the way that libpq
+// does. In order of precedence, the parameters come from the command
+// line flags, the -dsn connection string (or URL), the service file
+// entry for the service, the PG* environment variables, and the
+// defaults. When no password is given the password file is checked.
+func connParams(args cArgs) (p map[string]string, err error) {
+
+	p = make(map[string]string)
+
+	if args.dsn != "" {
+		p, err = parseDSN(args.dsn)
+		if err != nil {
+			return
+		}
+	}
+
+	flags := map[string]string{
+		"host":        args.dbHost,
+		"dbname":      args.dbName,
+		"user":        args.dbUser,
+		"sslmode":     args.sslMode,
+		"sslrootcert": args.sslRootCert,
+		"sslcert":     args.sslCert,
+		"sslkey":      args.sslKey,
+		"service":     args.service,
+	}
+	if args.dbPort != 0 {
+		flags["port"] = fmt.Sprintf("%d", args.dbPort)
+	}
+	for k, v := range flags {
+		if v != "" {
+			p[k] = v
+		}
+	}
+
+	service := p["service"]
+	if service == "" {
+		service = os.Getenv("PGSERVICE")
+	}
+	delete(p, "service")
+	if service != "" {
+		var sp map[string]string
+		sp, err = serviceParams(service)
+		if err != nil {
+			return
+		}
+		setDefaults(p, sp)
+	}
+
+	env := make(map[string]string)
+	for k, v := range connEnvVars {
+		env[v] = os.Getenv(k)
+	}
+	setDefaults(p, env)
+
+	defaults := map[string]string{
+		"host": "localhost",
+		"port": "5432",
+	}
+	cu, errq := user.Current()
+	if errq == nil {
+		defaults["user"] = cu.Username
+	}
+	setDefaults(p, defaults)
+	setDefaults(p, map[string]string{"dbname": p["user"]})
+
+	if p["password"] == "" {
+		p["password"] = pgpassPassword(p)
+	}
+
+	for k, v := range p {
+		if v == "" {
+			delete(p, k)
+		}
+	}
+	return
+}
+
+// setDefaults sets the parameters that have not already been set
+func setDefaults(p, d map[string]string) {
+	for k, v := range d {
+		if p[k] == "" && v != "" {
+			p[k] = v
+		}
+	}
+}
+
+// connString returns the key=value connection string for the parameters
+func connString(p map[string]string) string {
+
+	var keys []string
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ary []string
+	for _, k := range keys {
+		v := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(p[k])
+		ary = append(ary, fmt.Sprintf("%s='%s'", k, v))
+	}
+	return strings.Join(ary, " ")
+}
+
+// parseDSN parses a key=value connection string, or a postgres:// (or
+// postgresql://) URL, into the connection parameters
+func parseDSN(dsn string) (p map[string]string, err error) {
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return parseConnURL(dsn)
+	}
+
+	p = make(map[string]string)
+	s := []rune(dsn)
+
+	for i := 0; i < len(s); {
+
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != ' ' {
+			i++
+		}
+		key := string(s[start:i])
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) || s[i] != '=' {
+			err = fmt.Errorf("Missing \"=\" after %q in the connection string", key)
+			return
+		}
+		i++
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+
+		var v strings.Builder
+		quoted := i < len(s) && s[i] == '\''
+		if quoted {
+			i++
+		}
+		for ; i < len(s); i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				v.WriteRune(s[i])
+				continue
+			}
+			if quoted && s[i] == '\'' {
+				break
+			}
+			if !quoted && s[i] == ' ' {
+				break
+			}
+			v.WriteRune(s[i])
+		}
+		if quoted {
+			if i >= len(s) {
+				err = fmt.Errorf("Unterminated quoted value for %q in the connection string", key)
+				return
+			}
+			i++
+		}
+		p[key] = v.String()
+	}
+	return
+}
+
+// parseConnURL parses a postgres:// URL into the connection parameters.
+// A unix socket directory can be given as the host query parameter.
+func parseConnURL(dsn string) (p map[string]string, err error) {
+
+	pu, err := url.Parse(dsn)
+	if err != nil {
+		return
+	}
+
+	p = make(map[string]string)
+
+	if pu.User != nil {
+		p["user"] = pu.User.Username()
+		p["password"], _ = pu.User.Password()
+	}
+	p["host"] = pu.Hostname()
+	p["port"] = pu.Port()
+	p["dbname"] = strings.TrimPrefix(pu.Path, "/")
+
+	for k, v := range pu.Query() {
+		if len(v) > 0 {
+			p[k] = v[len(v)-1]
+		}
+	}
+	return
+}
+
+// serviceParams returns the connection parameters for a service from
+// the user service file (~/.pg_service.conf, or PGSERVICEFILE) or, if
+// not found there, the system service file (pg_service.conf in
+// PGSYSCONFDIR)
+func serviceParams(service string) (p map[string]string, err error) {
+
+	var files []string
+	if s := os.Getenv("PGSERVICEFILE"); s != "" {
+		files = append(files, s)
+	} else if home, errq := os.UserHomeDir(); errq == nil {
+		files = append(files, filepath.Join(home, ".pg_service.conf"))
+	}
+	if s := os.Getenv("PGSYSCONFDIR"); s != "" {
+		files = append(files, filepath.Join(s, "pg_service.conf"))
+	}
+
+	for _, fileName := range files {
+		var ok bool
+		p, ok, err = readServiceFile(fileName, service)
+		if err != nil || ok {
+			return
+		}
+	}
+
+	err = fmt.Errorf("Definition of service %q not found", service)
+	return
+}
+
+// readServiceFile reads the parameters for a service from a service file
+func readServiceFile(fileName, service string) (p map[string]string, ok bool, err error) {
+
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	p = make(map[string]string)
+	var section string
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				err = fmt.Errorf("Syntax error in service file %q, line %d", fileName, lineNo)
+				return
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == service {
+				ok = true
+			}
+			continue
+		}
+
+		if section != service {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			err = fmt.Errorf("Syntax error in service file %q, line %d", fileName, lineNo)
+			return
+		}
+		p[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	err = scanner.Err()
+	return
+}
+
+// pgpassPassword returns the password, if any, from the password file
+// (~/.pgpass, or PGPASSFILE) for the connection parameters. As with
+// libpq, a unix socket host matches "localhost" and the file is ignored
+// if it can be read by the group or others.
+func pgpassPassword(p map[string]string) string {
+
+	fileName := os.Getenv("PGPASSFILE")
+	if fileName == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		fileName = filepath.Join(home, ".pgpass")
+	}
+
+	fi, err := os.Stat(fileName)
+	if err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: password file %q has group or world access; permissions should be u=rw (0600) or less\n", fileName)
+		return ""
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	host := p["host"]
+	if host == "" || strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+	want := []string{host, p["port"], p["dbname"], p["user"]}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+
+		match := true
+		for i, s := range want {
+			if fields[i] != "*" && fields[i] != s {
+				match = false
+				break
+			}
+		}
+		if match {
+			return fields[4]
+		}
+	}
+	return ""
+}
+
+// splitPgpassLine splits a password file line on the unescaped colons
+func splitPgpassLine(line string) (fields []string) {
+
+	var b strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':':
+			fields = append(fields, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(fields, b.String())
+}
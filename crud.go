@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+// genQuerierCode generates the interface that the generated database
+// calls are made against
+func genQuerierCode(args cArgs) {
+
+	cb := u.NewLineBuf()
+
+	cb.Append("// Querier is the subset of the database/sql methods used by the generated code.")
+	cb.Append("// It is satisfied by *sql.DB, *sql.Conn, and *sql.Tx.")
+	cb.Append("type Querier interface {")
+	cb.Append("\tExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)")
+	cb.Append("\tQueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)")
+	cb.Append("\tQueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row")
+	cb.Append("}")
+	cb.Append("")
+
+	writeCode(args, "querier", "", cb)
+}
+
+// reservedReceivers are the parameter and local variable names used in
+// the generated methods that a receiver name must not collide with
+var reservedReceivers = map[string]bool{
+	"d": true, // the array Scan methods
+	"i": true, // the array Scan and Value methods
+	"q": true, // the Querier parameter
+	"r": true, // the navigation methods
+	"v": true, // the enum Scan and domain Validate methods
+}
+
+// receiverName returns the name to use for the receiver of the methods
+// for a struct. Names that would collide with the parameters or local
+// variables of the generated methods get an "x" appended.
+func receiverName(structName string) string {
+	rn := strings.ToLower(structName[:1])
+	if reservedReceivers[rn] {
+		rn += "x"
+	}
+	return rn
+}
+
+// qualifiedName returns the quoted, schema qualified, name of a database object
+func qualifiedName(schemaName, objName string) string {
+	return fmt.Sprintf("%s.%s", u.QuoteIdent(schemaName), u.QuoteIdent(objName))
+}
+
+// genTableCrud generates the insert, select, update, and delete methods
+// for a table struct based on the privileges that the application user
+// has on the table. Insert and Update only write those columns that the
+// user has the column privilege for. Insert leaves out the identity
+// ALWAYS and generated columns, inserts the other defaulted columns as
+// DEFAULT when their fields are NULL (or leaves them out when there are
+// no NULL fields, as with the none null style), and scans the values
+// that the database assigns back into the struct.
+func genTableCrud(args cArgs, f m.PgTableMetadata, cb *u.LineBuf) (err error) {
+
+	switch f.ObjKind {
+	case "r", "p", "v", "f":
+	default:
+		return
+	}
+
+	var pkCols []m.PgColumnMetadata
+	var nonPkCols []m.PgColumnMetadata
+	var insertCols []m.PgColumnMetadata
+	var returningCols []m.PgColumnMetadata
+	var defaultedCols bool
+	for _, col := range f.Columns {
+		if col.IsPk {
+			pkCols = append(pkCols, col)
+		} else if col.Privileges.Update && col.Generated == "" && col.Identity != "a" {
+			nonPkCols = append(nonPkCols, col)
+		}
+		switch {
+		case col.Identity == "a", col.Generated != "":
+			returningCols = append(returningCols, col)
+		case col.Privileges.Insert && hasDefault(col) && m.NullStyle() == m.NullStyleNone:
+			// the fields can not be NULL so there is no way to ask for the default
+			returningCols = append(returningCols, col)
+		case col.Privileges.Insert:
+			insertCols = append(insertCols, col)
+			defaultedCols = defaultedCols || hasDefault(col)
+		case hasDefault(col):
+			returningCols = append(returningCols, col)
+		}
+	}
+
+	rn := receiverName(f.StructName)
+	tableName := qualifiedName(f.SchemaName, f.ObjName)
+
+	// scanFields is used by any of the select methods
+	if f.Privileges.Select {
+		cb.Append(fmt.Sprintf("// scanFields returns the pointers to the %s fields, in column order, for scanning into", f.StructName))
+		cb.Append(fmt.Sprintf("func (%s *%s) scanFields() []interface{} {", rn, f.StructName))
+		cb.Append("\treturn []interface{}{")
+		for _, col := range f.Columns {
+			cb.Append(fmt.Sprintf("\t\t&%s.%s,", rn, u.ToUpperCamelCase(col.ColumnName)))
+		}
+		cb.Append("\t}")
+		cb.Append("}")
+		cb.Append("")
+	}
+
+	if f.Privileges.Insert && defaultedCols {
+		genDefaultedInsert(f, rn, tableName, insertCols, returningCols, cb)
+	} else if f.Privileges.Insert && len(insertCols)+len(returningCols) > 0 {
+		cb.Append(fmt.Sprintf("// Insert inserts the %s into the %s.%s %s", f.StructName, f.SchemaName, f.ObjName, f.ObjType))
+		cb.Append(fmt.Sprintf("func (%s *%s) Insert(ctx context.Context, q Querier) error {", rn, f.StructName))
+		cb.Append("")
+
+		stmt := fmt.Sprintf("\tstmt := `INSERT INTO %s", tableName)
+		if len(insertCols) > 0 {
+			cb.Append(stmt + " (")
+			cb.Append(fmt.Sprintf("        %s )", columnList(insertCols, ",\n        ")))
+			stmt = fmt.Sprintf("    VALUES ( %s )", placeholderList(1, len(insertCols)))
+		} else {
+			stmt += " DEFAULT VALUES"
+		}
+		if len(returningCols) > 0 {
+			cb.Append(stmt)
+			stmt = "    RETURNING " + columnList(returningCols, ",\n        ")
+		}
+		cb.Append(stmt + "`")
+		cb.Append("")
+
+		if len(returningCols) > 0 {
+			scan := fmt.Sprintf(".Scan(%s)", fieldScanList(returningCols, "&"+rn))
+			if len(insertCols) > 0 {
+				cb.Append("\treturn q.QueryRowContext(ctx, stmt,")
+				appendFieldArgs(rn, insertCols, cb)
+				cb.Append("\t)" + scan)
+			} else {
+				cb.Append("\treturn q.QueryRowContext(ctx, stmt)" + scan)
+			}
+		} else {
+			cb.Append("\t_, err := q.ExecContext(ctx, stmt,")
+			appendFieldArgs(rn, insertCols, cb)
+			cb.Append("\t)")
+			cb.Append("\treturn err")
+		}
+		cb.Append("}")
+		cb.Append("")
+	}
+
+	// The remaining methods all require a primary key
+	if len(pkCols) == 0 {
+		return
+	}
+
+	if f.Privileges.Select {
+		cb.Append(fmt.Sprintf("// SelectByPK populates the %s from the %s.%s %s using the primary key field values", f.StructName, f.SchemaName, f.ObjName, f.ObjType))
+		cb.Append(fmt.Sprintf("func (%s *%s) SelectByPK(ctx context.Context, q Querier) error {", rn, f.StructName))
+		cb.Append("")
+		cb.Append("\tstmt := `SELECT " + columnList(f.Columns, ",\n        "))
+		cb.Append(fmt.Sprintf("    FROM %s", tableName))
+		cb.Append(fmt.Sprintf("    WHERE %s`", predicateList(pkCols, 1)))
+		cb.Append("")
+		cb.Append("\treturn q.QueryRowContext(ctx, stmt,")
+		appendFieldArgs(rn, pkCols, cb)
+		cb.Append(fmt.Sprintf("\t).Scan(%s.scanFields()...)", rn))
+		cb.Append("}")
+		cb.Append("")
+	}
+
+	if f.Privileges.Update && len(nonPkCols) > 0 {
+		var sets []string
+		for i, col := range nonPkCols {
+			sets = append(sets, fmt.Sprintf("%s = $%d", u.QuoteIdent(col.ColumnName), i+1))
+		}
+
+		cb.Append(fmt.Sprintf("// Update updates the %s.%s %s from the %s using the primary key field values", f.SchemaName, f.ObjName, f.ObjType, f.StructName))
+		cb.Append(fmt.Sprintf("func (%s *%s) Update(ctx context.Context, q Querier) error {", rn, f.StructName))
+		cb.Append("")
+		cb.Append(fmt.Sprintf("\tstmt := `UPDATE %s", tableName))
+		cb.Append(fmt.Sprintf("    SET %s", strings.Join(sets, ",\n        ")))
+		cb.Append(fmt.Sprintf("    WHERE %s`", predicateList(pkCols, len(nonPkCols)+1)))
+		cb.Append("")
+		cb.Append("\t_, err := q.ExecContext(ctx, stmt,")
+		appendFieldArgs(rn, nonPkCols, cb)
+		appendFieldArgs(rn, pkCols, cb)
+		cb.Append("\t)")
+		cb.Append("\treturn err")
+		cb.Append("}")
+		cb.Append("")
+	}
+
+	if f.Privileges.Delete {
+		cb.Append(fmt.Sprintf("// Delete deletes the %s from the %s.%s %s using the primary key field values", f.StructName, f.SchemaName, f.ObjName, f.ObjType))
+		cb.Append(fmt.Sprintf("func (%s *%s) Delete(ctx context.Context, q Querier) error {", rn, f.StructName))
+		cb.Append("")
+		cb.Append(fmt.Sprintf("\tstmt := `DELETE FROM %s", tableName))
+		cb.Append(fmt.Sprintf("    WHERE %s`", predicateList(pkCols, 1)))
+		cb.Append("")
+		cb.Append("\t_, err := q.ExecContext(ctx, stmt,")
+		appendFieldArgs(rn, pkCols, cb)
+		cb.Append("\t)")
+		cb.Append("\treturn err")
+		cb.Append("}")
+		cb.Append("")
+	}
+
+	return
+}
+
+// hasDefault returns true if the database supplies the value of a column
+// that is not inserted
+func hasDefault(col m.PgColumnMetadata) bool {
+	return col.HasDefault || col.Identity != "" || col.Generated != ""
+}
+
+// genDefaultedInsert generates the Insert method for a table that has
+// insertable columns with defaults. As those columns are only inserted
+// when their fields are not NULL the statement is built when called.
+func genDefaultedInsert(f m.PgTableMetadata, rn, tableName string, insertCols, returningCols []m.PgColumnMetadata, cb *u.LineBuf) {
+
+	var names []string
+	for _, col := range insertCols {
+		if hasDefault(col) {
+			names = append(names, col.ColumnName)
+		}
+	}
+
+	cb.Append(fmt.Sprintf("// Insert inserts the %s into the %s.%s %s.", f.StructName, f.SchemaName, f.ObjName, f.ObjType))
+	cb.Append(fmt.Sprintf("// The columns with defaults (%s) are inserted as DEFAULT when their", strings.Join(names, ", ")))
+	cb.Append("// fields are NULL, and the values that the database assigns are")
+	cb.Append(fmt.Sprintf("// scanned back into the %s.", f.StructName))
+	cb.Append(fmt.Sprintf("func (%s *%s) Insert(ctx context.Context, q Querier) error {", rn, f.StructName))
+	cb.Append("")
+	cb.Append(fmt.Sprintf("	ins := insertStmt{table: `%s`}", tableName))
+	for _, col := range insertCols {
+		name := u.QuoteIdent(col.ColumnName)
+		field := rn + "." + u.ToUpperCamelCase(col.ColumnName)
+		if hasDefault(col) {
+			cb.Append(fmt.Sprintf("	ins.valueOrDefault(`%s`, %s, &%s)", name, field, field))
+		} else {
+			cb.Append(fmt.Sprintf("	ins.value(`%s`, %s)", name, field))
+		}
+	}
+	for _, col := range returningCols {
+		cb.Append(fmt.Sprintf("	ins.returning(`%s`, &%s.%s)", u.QuoteIdent(col.ColumnName), rn, u.ToUpperCamelCase(col.ColumnName)))
+	}
+	cb.Append("")
+	cb.Append("	return ins.exec(ctx, q)")
+	cb.Append("}")
+	cb.Append("")
+}
+
+// hasDefaultedInserts returns true if any of the tables has an Insert
+// method that uses the insert support code
+func hasDefaultedInserts(tables map[string]m.PgTableMetadata) bool {
+	for _, f := range tables {
+		switch f.ObjKind {
+		case "r", "p", "v", "f":
+		default:
+			continue
+		}
+		if !f.Privileges.Insert {
+			continue
+		}
+		for _, col := range f.Columns {
+			if col.Privileges.Insert && col.Identity != "a" && col.Generated == "" && hasDefault(col) && m.NullStyle() != m.NullStyleNone {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// genInsertSupportCode generates the support code for the Insert methods
+// of the tables that have insertable columns with defaults
+func genInsertSupportCode(args cArgs, tables map[string]m.PgTableMetadata) {
+
+	if !hasDefaultedInserts(tables) {
+		return
+	}
+
+	isNull := isNullSupport
+	if m.NullStyle() == m.NullStylePgtype {
+		isNull = isNullPgtypeSupport
+	}
+
+	cb := u.NewLineBuf()
+	for _, s := range append(insertSupport, isNull...) {
+		cb.Append(s)
+	}
+	writeCode(args, "insertsupport", "", cb)
+}
+
+var insertSupport = []string{
+	`// insertStmt builds the INSERT statement for a row, leaving out the`,
+	`// defaulted columns that have no value and returning the values that`,
+	`// the database assigns`,
+	`type insertStmt struct {`,
+	`	table string        // the quoted, schema qualified, table name`,
+	`	cols  []string      // the inserted columns`,
+	`	args  []interface{} // the inserted values`,
+	`	ret   []string      // the returned columns`,
+	`	dest  []interface{} // the fields to scan the returned values into`,
+	`	err   error         // the first error converting a value`,
+	`}`,
+	``,
+	`// value adds a column to insert`,
+	`func (s *insertStmt) value(col string, v interface{}) {`,
+	`	s.cols = append(s.cols, col)`,
+	`	s.args = append(s.args, v)`,
+	`}`,
+	``,
+	`// valueOrDefault adds a column that has a default. A NULL value is left`,
+	`// to the default and the assigned value is scanned into dest.`,
+	`func (s *insertStmt) valueOrDefault(col string, v, dest interface{}) {`,
+	``,
+	`	isNull, err := isNullValue(v)`,
+	`	if err != nil {`,
+	`		if s.err == nil {`,
+	`			s.err = fmt.Errorf("%s: %w", col, err)`,
+	`		}`,
+	`		return`,
+	`	}`,
+	`	if isNull {`,
+	`		s.returning(col, dest)`,
+	`		return`,
+	`	}`,
+	`	s.value(col, v)`,
+	`}`,
+	``,
+	`// returning adds a column whose value is assigned by the database`,
+	`func (s *insertStmt) returning(col string, dest interface{}) {`,
+	`	s.ret = append(s.ret, col)`,
+	`	s.dest = append(s.dest, dest)`,
+	`}`,
+	``,
+	`// exec runs the INSERT statement`,
+	`func (s *insertStmt) exec(ctx context.Context, q Querier) error {`,
+	``,
+	`	if s.err != nil {`,
+	`		return s.err`,
+	`	}`,
+	``,
+	`	stmt := "INSERT INTO " + s.table`,
+	`	if len(s.cols) > 0 {`,
+	`		var ph []string`,
+	`		for i := range s.cols {`,
+	`			ph = append(ph, "$"+strconv.Itoa(i+1))`,
+	`		}`,
+	`		stmt += " ( " + strings.Join(s.cols, ", ") + " ) VALUES ( " + strings.Join(ph, ", ") + " )"`,
+	`	} else {`,
+	`		stmt += " DEFAULT VALUES"`,
+	`	}`,
+	``,
+	`	if len(s.ret) == 0 {`,
+	`		_, err := q.ExecContext(ctx, stmt, s.args...)`,
+	`		return err`,
+	`	}`,
+	`	stmt += " RETURNING " + strings.Join(s.ret, ", ")`,
+	`	return q.QueryRowContext(ctx, stmt, s.args...).Scan(s.dest...)`,
+	`}`,
+	``,
+}
+
+// isNullSupport is the insert support code for checking for NULL values
+var isNullSupport = []string{
+	`// isNullValue returns true if a value is NULL`,
+	`func isNullValue(v interface{}) (bool, error) {`,
+	`	dv, err := driver.DefaultParameterConverter.ConvertValue(v)`,
+	`	return err == nil && dv == nil, err`,
+	`}`,
+}
+
+// isNullPgtypeSupport is the insert support code for checking for NULL
+// values when the pgtype types are used. As the zero pgtype values are
+// Undefined (which can not be written) these are also taken to be NULL.
+var isNullPgtypeSupport = []string{
+	`// isNullValue returns true if a value is NULL, or is a pgtype value that`,
+	`// has not been set`,
+	`func isNullValue(v interface{}) (bool, error) {`,
+	``,
+	`	if g, ok := v.(interface{ Get() interface{} }); ok && g.Get() == pgtype.Undefined {`,
+	`		return true, nil`,
+	`	}`,
+	``,
+	`	dv, err := driver.DefaultParameterConverter.ConvertValue(v)`,
+	`	return err == nil && dv == nil, err`,
+	`}`,
+}
+
+// selectableColumns returns the columns that the application user can
+// select, which are those that are generated in the table struct. When
+// any of the primary key columns can not be selected then the primary
+// key can not be used and none of the columns are flagged as being in it.
+func selectableColumns(cols []m.PgColumnMetadata) (d []m.PgColumnMetadata) {
+
+	pkUsable := true
+	for _, col := range cols {
+		if col.IsPk && !col.Privileges.Select {
+			pkUsable = false
+		}
+	}
+
+	for _, col := range cols {
+		if !col.Privileges.Select {
+			continue
+		}
+		if !pkUsable {
+			col.IsPk = false
+		}
+		d = append(d, col)
+	}
+	return
+}
+
+// hasColumns checks that all of the named columns are in the columns
+func hasColumns(cols []m.PgColumnMetadata, names []string) bool {
+	for _, name := range names {
+		found := false
+		for _, col := range cols {
+			if col.ColumnName == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// columnList returns the quoted column names joined by sep
+func columnList(cols []m.PgColumnMetadata, sep string) string {
+	var ary []string
+	for _, col := range cols {
+		ary = append(ary, u.QuoteIdent(col.ColumnName))
+	}
+	return strings.Join(ary, sep)
+}
+
+// placeholderList returns the list of n bind placeholders starting at $start
+func placeholderList(start, n int) string {
+	var ary []string
+	for i := 0; i < n; i++ {
+		ary = append(ary, fmt.Sprintf("$%d", start+i))
+	}
+	return strings.Join(ary, ", ")
+}
+
+// predicateList returns the "column = $n" predicates for the columns
+// with the placeholders numbered from $start
+func predicateList(cols []m.PgColumnMetadata, start int) string {
+	var names []string
+	for _, col := range cols {
+		names = append(names, col.ColumnName)
+	}
+	return namedPredicateList(names, start)
+}
+
+// namedPredicateList returns the "column = $n" predicates for the named
+// columns with the placeholders numbered from $start
+func namedPredicateList(cols []string, start int) string {
+	var ary []string
+	for i, col := range cols {
+		ary = append(ary, fmt.Sprintf("%s = $%d", u.QuoteIdent(col), start+i))
+	}
+	return strings.Join(ary, "\n        AND ")
+}
+
+// appendFieldArgs appends the struct fields for the columns as call arguments
+func appendFieldArgs(rn string, cols []m.PgColumnMetadata, cb *u.LineBuf) {
+	for _, col := range cols {
+		cb.Append(fmt.Sprintf("\t\t%s.%s,", rn, u.ToUpperCamelCase(col.ColumnName)))
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+// cursorField is a field of the struct for the rows fetched from the
+// refcursors returned by a function
+type cursorField struct {
+	FieldName string
+	Row       rowStruct
+}
+
+// cursorFields returns the fields, one for each declared refcursor, of
+// the struct for the rows fetched from the refcursors returned by a
+// function. The fields are named for the declared cursor names, or else
+// for the OUT refcursor arguments, or else for the row structs. The
+// position is appended to any names that are still used for more than
+// one cursor.
+func cursorFields(f m.PgFunctionMetadata, rows map[string]rowStruct) (d []cursorField, err error) {
+
+	count := make(map[string]int)
+	for i, k := range f.Cursors {
+		rs, ok := rows[k]
+		if !ok {
+			err = fmt.Errorf("cursorFields - no struct is generated for the %s cursor type", k)
+			return
+		}
+
+		name := rs.StructName
+		switch {
+		case i < len(f.CursorNames) && f.CursorNames[i] != "":
+			name = u.ToUpperCamelCase(f.CursorNames[i])
+		case !f.ReturnsSet && i < len(f.ResultColumns) && f.ResultColumns[i].ColumnName != "":
+			name = u.ToUpperCamelCase(f.ResultColumns[i].ColumnName)
+		}
+		count[name]++
+		d = append(d, cursorField{name, rs})
+	}
+
+	for i, c := range d {
+		if count[c.FieldName] > 1 {
+			d[i].FieldName = fmt.Sprintf("%s%d", c.FieldName, i+1)
+		}
+	}
+	return
+}
+
+// genCursorStruct generates the struct for the rows fetched from the
+// refcursors returned by a function
+func genCursorStruct(args cArgs, f m.PgFunctionMetadata, rows map[string]rowStruct, cb *u.LineBuf) (err error) {
+
+	fields, err := cursorFields(f, rows)
+	if err != nil {
+		return
+	}
+
+	// Functions returning a single row declare the cursors of that row
+	if !f.ReturnsSet && len(fields) != len(f.ResultColumns) {
+		err = fmt.Errorf("genCursorStruct - %d cursor types declared for %d refcursors", len(fields), len(f.ResultColumns))
+		return
+	}
+
+	cb.Append(fmt.Sprintf("// %s struct for the rows fetched from the refcursors returned by the %s.%s function", f.StructName, f.SchemaName, f.ObjName))
+	cb.Append(fmt.Sprintf("type %s struct {", f.StructName))
+	for _, c := range fields {
+		cb.Append(fmt.Sprintf("\t%s []%s", c.FieldName, c.Row.StructName))
+	}
+	cb.Append("}")
+	cb.Append("")
+	return
+}
+
+// appendCursorCall appends the code for calling a function that returns
+// refcursors. The function is called in a transaction, as the cursors
+// only exist until the end of the transaction, and the rows of each
+// cursor are fetched before the cursor is closed. The rows are scanned
+// by column name, as the cursors may return columns that are not in the
+// row struct.
+func appendCursorCall(f m.PgFunctionMetadata, rows map[string]rowStruct, callArgs string, callCols []m.PgColumnMetadata, cb *u.LineBuf) (err error) {
+
+	fields, err := cursorFields(f, rows)
+	if err != nil {
+		return
+	}
+
+	cb.Append(fmt.Sprintf("\tvar r %s", f.StructName))
+	cb.Append("\ttx, err := beginCursorTx(ctx, q)")
+	cb.Append("\tif err != nil {")
+	cb.Append("\t\treturn r, err")
+	cb.Append("\t}")
+	cb.Append("\tdefer tx.rollback()")
+	cb.Append("")
+	appendCall("\tcursors, err := cursorNames(ctx, tx, "+callArgs, callCols, ")", cb)
+	cb.Append("\tif err != nil {")
+	cb.Append("\t\treturn r, err")
+	cb.Append("\t}")
+	cb.Append(fmt.Sprintf("\tif len(cursors) != %d {", len(fields)))
+	cb.Append(fmt.Sprintf("\t\treturn r, fmt.Errorf(\"%s.%s: expected %d cursors, got %%d\", len(cursors))", f.SchemaName, f.ObjName, len(fields)))
+	cb.Append("\t}")
+
+	for i, c := range fields {
+		cb.Append("")
+		cb.Append(fmt.Sprintf("\terr = fetchCursor(ctx, tx, cursors[%d], func(rows *sql.Rows, cols []string) error {", i))
+		cb.Append(fmt.Sprintf("\t\tvar c %s", c.Row.StructName))
+		cb.Append("\t\terr := scanColumns(rows, cols, map[string]interface{}{")
+		for _, col := range c.Row.Columns {
+			cb.Append(fmt.Sprintf("\t\t\t%q: %s,", col.ColumnName, scanDest("&c."+u.ToUpperCamelCase(col.ColumnName), col)))
+		}
+		cb.Append("\t\t})")
+		cb.Append(fmt.Sprintf("\t\tr.%s = append(r.%s, c)", c.FieldName, c.FieldName))
+		cb.Append("\t\treturn err")
+		cb.Append("\t})")
+		cb.Append("\tif err != nil {")
+		cb.Append("\t\treturn r, err")
+		cb.Append("\t}")
+	}
+
+	cb.Append("")
+	cb.Append("\treturn r, tx.commit()")
+	return
+}
+
+// hasCursorFunctions returns true if any of the functions return
+// declared refcursors
+func hasCursorFunctions(d []m.PgFunctionMetadata) bool {
+	for _, f := range d {
+		if len(f.Cursors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// genCursorSupportCode generates the support code for calling the
+// functions that return refcursors
+func genCursorSupportCode(args cArgs, d []m.PgFunctionMetadata) {
+
+	if !hasCursorFunctions(d) {
+		return
+	}
+
+	cb := u.NewLineBuf()
+	for _, s := range cursorSupport {
+		cb.Append(s)
+	}
+	writeCode(args, "cursorsupport", "", cb)
+}
+
+var cursorSupport = []string{
+	`// Beginner is the database/sql method used for beginning the transaction`,
+	`// that the functions returning refcursors are called in. It is satisfied`,
+	`// by *sql.DB and *sql.Conn.`,
+	`type Beginner interface {`,
+	`	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)`,
+	`}`,
+	``,
+	`// cursorTx is the transaction that a function returning refcursors is`,
+	`// called in. The transaction is only begun, and ended, here when the`,
+	`// Querier passed in is not already a transaction (as a *sql.Tx is).`,
+	`type cursorTx struct {`,
+	`	Querier`,
+	`	tx *sql.Tx // the transaction begun, if any`,
+	`}`,
+	``,
+	`// beginCursorTx returns the transaction for calling a function that`,
+	`// returns refcursors, beginning one when q is a Beginner. Any other`,
+	`// Querier is an error as the cursors would be closed before they could`,
+	`// be fetched from if the function were not called in a transaction.`,
+	`func beginCursorTx(ctx context.Context, q Querier) (*cursorTx, error) {`,
+	``,
+	`	switch x := q.(type) {`,
+	`	case *sql.Tx:`,
+	`		return &cursorTx{Querier: x}, nil`,
+	`	case Beginner:`,
+	`		tx, err := x.BeginTx(ctx, nil)`,
+	`		if err != nil {`,
+	`			return nil, err`,
+	`		}`,
+	`		return &cursorTx{Querier: tx, tx: tx}, nil`,
+	`	}`,
+	`	return nil, fmt.Errorf("cannot fetch refcursors with a %T, which is neither a *sql.Tx nor a Beginner", q)`,
+	`}`,
+	``,
+	`// commit commits the transaction, if it was begun by beginCursorTx`,
+	`func (t *cursorTx) commit() error {`,
+	`	if t.tx == nil {`,
+	`		return nil`,
+	`	}`,
+	`	return t.tx.Commit()`,
+	`}`,
+	``,
+	`// rollback rolls back the transaction, if it was begun by beginCursorTx`,
+	`// and has not been committed`,
+	`func (t *cursorTx) rollback() {`,
+	`	if t.tx != nil {`,
+	`		t.tx.Rollback()`,
+	`	}`,
+	`}`,
+	``,
+	`// cursorNames calls a function that returns refcursors and returns the`,
+	`// names of the cursors, in order`,
+	`func cursorNames(ctx context.Context, q Querier, query string, args ...interface{}) ([]string, error) {`,
+	``,
+	`	rows, err := q.QueryContext(ctx, query, args...)`,
+	`	if err != nil {`,
+	`		return nil, err`,
+	`	}`,
+	`	defer rows.Close()`,
+	``,
+	`	cols, err := rows.Columns()`,
+	`	if err != nil {`,
+	`		return nil, err`,
+	`	}`,
+	``,
+	`	var d []string`,
+	`	for rows.Next() {`,
+	`		names := make([]string, len(cols))`,
+	`		dest := make([]interface{}, len(cols))`,
+	`		for i := range names {`,
+	`			dest[i] = &names[i]`,
+	`		}`,
+	`		err = rows.Scan(dest...)`,
+	`		if err != nil {`,
+	`			return nil, err`,
+	`		}`,
+	`		d = append(d, names...)`,
+	`	}`,
+	`	return d, rows.Err()`,
+	`}`,
+	``,
+	`// fetchCursor fetches all of the rows from a cursor, calling scan with`,
+	`// the column names for each row, and then closes the cursor`,
+	`func fetchCursor(ctx context.Context, q Querier, cursor string, scan func(rows *sql.Rows, cols []string) error) error {`,
+	``,
+	`	rows, err := q.QueryContext(ctx, "FETCH ALL FROM "+pq.QuoteIdentifier(cursor))`,
+	`	if err != nil {`,
+	`		return err`,
+	`	}`,
+	`	cols, err := rows.Columns()`,
+	`	if err != nil {`,
+	`		rows.Close()`,
+	`		return err`,
+	`	}`,
+	`	for rows.Next() {`,
+	`		err = scan(rows, cols)`,
+	`		if err != nil {`,
+	`			rows.Close()`,
+	`			return err`,
+	`		}`,
+	`	}`,
+	`	err = rows.Close()`,
+	`	if err == nil {`,
+	`		err = rows.Err()`,
+	`	}`,
+	`	if err != nil {`,
+	`		return err`,
+	`	}`,
+	``,
+	`	_, err = q.ExecContext(ctx, "CLOSE "+pq.QuoteIdentifier(cursor))`,
+	`	return err`,
+	`}`,
+	``,
+	`// scanColumns scans a row into the destinations for the named columns.`,
+	`// The columns that have no destination, such as those that the user`,
+	`// can not select from the table, are discarded.`,
+	`func scanColumns(rows *sql.Rows, cols []string, dest map[string]interface{}) error {`,
+	``,
+	`	d := make([]interface{}, len(cols))`,
+	`	for i, col := range cols {`,
+	`		p, ok := dest[col]`,
+	`		if !ok {`,
+	`			p = new(interface{})`,
+	`		}`,
+	`		d[i] = p`,
+	`	}`,
+	`	return rows.Scan(d...)`,
+	`}`,
+	``,
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+var (
+	reCast      = regexp.MustCompile(`::(?:character varying|double precision|bit varying|time(?:stamp)?(?: with(?:out)? time zone)?|"[^"]*"|[A-Za-z_][A-Za-z0-9_.]*)(?:\(\d+(?:\s*,\s*\d+)?\))?(?:\[\])*`)
+	reAtom      = regexp.MustCompile(`(^|[^A-Za-z0-9_])\((VALUE|-?[0-9]+(?:\.[0-9]+)?|\x01[0-9]+\x02)\)`)
+	reCompare   = regexp.MustCompile(`^VALUE (=|<>|!=|<|<=|>|>=) (-?[0-9]+(?:\.[0-9]+)?|\x01[0-9]+\x02)$`)
+	reCompareR  = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?|\x01[0-9]+\x02) (=|<>|!=|<|<=|>|>=) VALUE$`)
+	reMatch     = regexp.MustCompile(`^VALUE (~|~\*|!~|!~\*) \x01([0-9]+)\x02$`)
+	reLength    = regexp.MustCompile(`^(?:length|char_length|character_length)\(VALUE\) (=|<>|!=|<|<=|>|>=) ([0-9]+)$`)
+	reInList    = regexp.MustCompile(`^VALUE (= ANY|<> ALL) \(\(?ARRAY\[((?:\x01[0-9]+\x02(?:, )?)+)\]\)?\)$`)
+	reLiteral   = regexp.MustCompile(`\x01([0-9]+)\x02`)
+	reNotNull   = regexp.MustCompile(`^VALUE IS NOT NULL$`)
+	goOperators = map[string]string{"=": "==", "<>": "!=", "!=": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+	flipped     = map[string]string{"=": "=", "<>": "<>", "!=": "!=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+)
+
+// domainCheck contains a domain CHECK constraint as translated for
+// client-side validation
+type domainCheck struct {
+	def     string
+	cond    string
+	notNull bool
+	regexps []string
+}
+
+func genDomainCode(args cArgs, d []m.PgDomainMetadata) (err error) {
+
+	// As with types, the domains are keyed on the domain name only
+	seen := make(map[string]int)
+
+	for _, f := range d {
+
+		// ensure the domain has not been generated already
+		_, ok := seen[f.GoTypeName]
+		if ok {
+			continue
+		}
+		seen[f.GoTypeName] = 1
+
+		cb := u.NewLineBuf()
+
+		errq := genDomain(args, f, cb)
+		if errq != nil {
+			fmt.Printf("Failed to generate code for domain %q.%q\n", f.SchemaName, f.ObjName)
+			continue
+		}
+
+		writeCode(args, f.GoTypeName, fmt.Sprintf("%s.%s", f.SchemaName, f.ObjName), cb, m.TypeImports(f.TypeName)...)
+	}
+
+	if len(seen) > 0 {
+		genDomainSupportCode(args)
+	}
+
+	return
+}
+
+func genDomain(args cArgs, f m.PgDomainMetadata, cb *u.LineBuf) (err error) {
+
+	var baseType string
+	baseType, err = m.TranslateDomainBaseType(f.TypeName)
+	if err != nil {
+		return
+	}
+
+	// Types that are not built-in types are embedded so that their
+	// Scan and Value methods are promoted to the domain type
+	embedded := strings.Contains(baseType, ".") || u.ToUpperCamelCase(f.TypeName) == baseType
+	baseIsDomain := m.IsDomain(f.TypeName)
+
+	var checks []domainCheck
+	notNull := f.IsRequired
+	for _, def := range f.Checks {
+		c := translateCheck(def, f.TypeCategory)
+		notNull = notNull || c.notNull
+		checks = append(checks, c)
+	}
+
+	rn := receiverName(f.GoTypeName)
+	desc := fmt.Sprintf("%s.%s", f.SchemaName, f.ObjName)
+
+	var reNames []string
+	for i, c := range checks {
+		for j, p := range c.regexps {
+			reName := fmt.Sprintf("re%sCheck%d", f.GoTypeName, i+1)
+			if len(c.regexps) > 1 {
+				reName = fmt.Sprintf("%s_%d", reName, j+1)
+			}
+			reNames = append(reNames, fmt.Sprintf("%s = regexp.MustCompile(%q)", reName, p))
+			checks[i].cond = strings.Replace(checks[i].cond, fmt.Sprintf("\x03%d\x03", j), reName, 1)
+		}
+	}
+
+	cb.Append(fmt.Sprintf("// %s type for the %s domain", f.GoTypeName, desc))
+	if f.Description != "" {
+		cb.Append(fmt.Sprintf("// %s", strings.ReplaceAll(f.Description, "\n", "\n// ")))
+	}
+	if embedded {
+		cb.Append(fmt.Sprintf("type %s struct {", f.GoTypeName))
+		cb.Append(fmt.Sprintf("\t%s", baseType))
+		cb.Append("}")
+	} else {
+		cb.Append(fmt.Sprintf("type %s %s", f.GoTypeName, baseType))
+	}
+	cb.Append("")
+
+	if len(reNames) == 1 {
+		cb.Append(fmt.Sprintf("var %s", reNames[0]))
+		cb.Append("")
+	} else if len(reNames) > 1 {
+		cb.Append("var (")
+		for _, s := range reNames {
+			cb.Append("\t" + s)
+		}
+		cb.Append(")")
+		cb.Append("")
+	}
+
+	cb.Append(fmt.Sprintf("// Validate checks the %s against the NOT NULL and CHECK constraints of the %s domain", f.GoTypeName, desc))
+	cb.Append(fmt.Sprintf("func (%s %s) Validate() error {", rn, f.GoTypeName))
+	cb.Append("")
+
+	if baseIsDomain {
+		cb.Append(fmt.Sprintf("\terr := %s.%s.Validate()", rn, baseType))
+		cb.Append("\tif err != nil {")
+		cb.Append("\t\treturn err")
+		cb.Append("\t}")
+		cb.Append("")
+	}
+
+	nullCheck := "\t\treturn nil"
+	if notNull {
+		nullCheck = fmt.Sprintf("\t\treturn fmt.Errorf(\"%s may not be NULL\")", desc)
+	}
+
+	// An unset pgtype value can not be converted so it is checked as NULL
+	if pgtypeBased(f.TypeName) {
+		cb.Append(fmt.Sprintf("\tif g, ok := interface{}(%s).(interface{ Get() interface{} }); ok && g.Get() == pgtype.Undefined {", rn))
+		cb.Append(nullCheck)
+		cb.Append("\t}")
+	}
+
+	cb.Append(fmt.Sprintf("\tv, err := driver.DefaultParameterConverter.ConvertValue(%s)", rn))
+	cb.Append("\tif err != nil {")
+	cb.Append("\t\treturn err")
+	cb.Append("\t}")
+	cb.Append("\tif v == nil {")
+	cb.Append(nullCheck)
+	cb.Append("\t}")
+	cb.Append("")
+
+	for _, c := range checks {
+		cb.Append(fmt.Sprintf("\t// %s", c.def))
+		if c.cond == "" {
+			if !c.notNull {
+				cb.Append("\t// (not checked, unable to translate the constraint)")
+			}
+			cb.Append("")
+			continue
+		}
+		if strings.HasPrefix(c.cond, "!") && !strings.Contains(c.cond, " && ") {
+			cb.Append(fmt.Sprintf("\tif %s {", c.cond[1:]))
+		} else {
+			cb.Append(fmt.Sprintf("\tif !(%s) {", c.cond))
+		}
+		cb.Append(fmt.Sprintf("\t\treturn fmt.Errorf(\"%s violates %%s\", %q)", desc, c.def))
+		cb.Append("\t}")
+		cb.Append("")
+	}
+
+	cb.Append("\treturn nil")
+	cb.Append("}")
+	cb.Append("")
+
+	return
+}
+
+// pgtypeBased returns true if the Go type for a domain base type, or for
+// the base type of the domain that it is over, is a pgtype type
+func pgtypeBased(typeName string) bool {
+	for m.IsDomain(typeName) {
+		typeName = m.DomainBaseType(typeName)
+	}
+	n, err := m.TranslateDomainBaseType(typeName)
+	return err == nil && strings.HasPrefix(n, "pgtype.")
+}
+
+// translateCheck translates a domain CHECK constraint definition, as
+// returned by pg_get_constraintdef, into a Go condition on the domain
+// value (v). Only simple comparisons, regular expression matches,
+// length checks, and lists of values are supported.
+func translateCheck(def string, typeCategory string) (c domainCheck) {
+
+	c.def = def
+
+	// Replace the string literals with placeholders so that they are
+	// not affected by the normalizing of the definition
+	var literals []string
+	var b strings.Builder
+	inLiteral := false
+	var lit strings.Builder
+	for i := 0; i < len(def); i++ {
+		ch := def[i]
+		switch {
+		case !inLiteral && ch == '\'':
+			inLiteral = true
+			lit.Reset()
+		case inLiteral && ch == '\'' && i+1 < len(def) && def[i+1] == '\'':
+			lit.WriteByte('\'')
+			i++
+		case inLiteral && ch == '\'':
+			inLiteral = false
+			b.WriteString(fmt.Sprintf("\x01%d\x02", len(literals)))
+			literals = append(literals, lit.String())
+		case inLiteral:
+			lit.WriteByte(ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if inLiteral {
+		return
+	}
+
+	s := strings.TrimSpace(b.String())
+	s = strings.TrimSuffix(s, " NOT VALID")
+	if !strings.HasPrefix(s, "CHECK ") {
+		return
+	}
+	s = reCast.ReplaceAllString(strings.TrimPrefix(s, "CHECK "), "")
+	for {
+		t := reAtom.ReplaceAllString(s, "$1$2")
+		if t == s {
+			break
+		}
+		s = t
+	}
+
+	var conds []string
+	for _, expr := range splitTopLevel(stripParens(s), " AND ") {
+		expr = stripParens(expr)
+
+		var cond string
+		switch {
+		case reNotNull.MatchString(expr):
+			c.notNull = true
+			continue
+
+		case reCompare.MatchString(expr):
+			p := reCompare.FindStringSubmatch(expr)
+			cond = compareCond(p[1], p[2], literals, typeCategory)
+
+		case reCompareR.MatchString(expr):
+			p := reCompareR.FindStringSubmatch(expr)
+			cond = compareCond(flipped[p[2]], p[1], literals, typeCategory)
+
+		case reMatch.MatchString(expr):
+			p := reMatch.FindStringSubmatch(expr)
+			idx, _ := strconv.Atoi(p[2])
+			pattern := literals[idx]
+			if strings.HasSuffix(p[1], "*") {
+				pattern = "(?i)" + pattern
+			}
+			if _, errq := regexp.Compile(pattern); errq != nil {
+				break
+			}
+			cond = fmt.Sprintf("\x03%d\x03.MatchString(domainString(v))", len(c.regexps))
+			if strings.HasPrefix(p[1], "!") {
+				cond = "!" + cond
+			}
+			c.regexps = append(c.regexps, pattern)
+
+		case reLength.MatchString(expr):
+			p := reLength.FindStringSubmatch(expr)
+			cond = fmt.Sprintf("domainLength(v) %s %s", goOperators[p[1]], p[2])
+
+		case reInList.MatchString(expr):
+			p := reInList.FindStringSubmatch(expr)
+			var list []string
+			for _, lm := range reLiteral.FindAllStringSubmatch(p[2], -1) {
+				idx, _ := strconv.Atoi(lm[1])
+				list = append(list, strconv.Quote(literals[idx]))
+			}
+			cond = fmt.Sprintf("domainIn(v, %s)", strings.Join(list, ", "))
+			if p[1] == "<> ALL" {
+				cond = "!" + cond
+			}
+		}
+
+		if cond == "" {
+			// unable to translate the check
+			c.regexps = nil
+			return
+		}
+		conds = append(conds, cond)
+	}
+
+	c.cond = strings.Join(conds, " && ")
+	return
+}
+
+// compareCond returns the Go condition for comparing the domain value
+// to a constant. Only the numeric (N) and string (S) type categories are
+// compared as the string form of other values, such as dates and
+// timestamps, does not order the same as the values.
+func compareCond(op, operand string, literals []string, typeCategory string) string {
+
+	if strings.HasPrefix(operand, "\x01") {
+		idx, _ := strconv.Atoi(strings.Trim(operand, "\x01\x02"))
+		operand = literals[idx]
+		if typeCategory == "S" {
+			return fmt.Sprintf("domainString(v) %s %s", goOperators[op], strconv.Quote(operand))
+		}
+	}
+
+	if _, err := strconv.ParseFloat(operand, 64); err != nil || typeCategory != "N" {
+		return ""
+	}
+	return fmt.Sprintf("domainNumber(v) %s %s", goOperators[op], operand)
+}
+
+// stripParens removes any parentheses that enclose the entire expression
+func stripParens(s string) string {
+	for strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		depth := 0
+		for i := 0; i < len(s); i++ {
+			switch s[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 && i < len(s)-1 {
+				return s
+			}
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// splitTopLevel splits an expression on the separator where the
+// separator is not enclosed in parentheses
+func splitTopLevel(s, sep string) (ary []string) {
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth == 0 && strings.HasPrefix(s[i:], sep) {
+			ary = append(ary, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(ary, s[start:])
+}
+
+// genDomainSupportCode generates the functions used by the generated
+// domain Validate methods
+func genDomainSupportCode(args cArgs) {
+
+	cb := u.NewLineBuf()
+
+	cb.Append("// domainString returns the text of a domain value for checking")
+	cb.Append("func domainString(v driver.Value) string {")
+	cb.Append("\tswitch x := v.(type) {")
+	cb.Append("\tcase string:")
+	cb.Append("\t\treturn x")
+	cb.Append("\tcase []byte:")
+	cb.Append("\t\treturn string(x)")
+	cb.Append("\t}")
+	cb.Append("\treturn fmt.Sprint(v)")
+	cb.Append("}")
+	cb.Append("")
+	cb.Append("// domainNumber returns the numeric value of a domain value for checking,")
+	cb.Append("// or NaN if the value is not numeric")
+	cb.Append("func domainNumber(v driver.Value) float64 {")
+	cb.Append("\tswitch x := v.(type) {")
+	cb.Append("\tcase int64:")
+	cb.Append("\t\treturn float64(x)")
+	cb.Append("\tcase float64:")
+	cb.Append("\t\treturn x")
+	cb.Append("\t}")
+	cb.Append("\tn, err := strconv.ParseFloat(domainString(v), 64)")
+	cb.Append("\tif err != nil {")
+	cb.Append("\t\treturn math.NaN()")
+	cb.Append("\t}")
+	cb.Append("\treturn n")
+	cb.Append("}")
+	cb.Append("")
+	cb.Append("// domainLength returns the length, in characters, of a domain value")
+	cb.Append("func domainLength(v driver.Value) int {")
+	cb.Append("\treturn utf8.RuneCountInString(domainString(v))")
+	cb.Append("}")
+	cb.Append("")
+	cb.Append("// domainIn returns true if the text of a domain value is in the list of values")
+	cb.Append("func domainIn(v driver.Value, list ...string) bool {")
+	cb.Append("\ts := domainString(v)")
+	cb.Append("\tfor _, l := range list {")
+	cb.Append("\t\tif s == l {")
+	cb.Append("\t\t\treturn true")
+	cb.Append("\t\t}")
+	cb.Append("\t}")
+	cb.Append("\treturn false")
+	cb.Append("}")
+	cb.Append("")
+
+	writeCode(args, "domainsupport", "", cb)
+}
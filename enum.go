@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+var reNonIdent = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func genEnumCode(args cArgs, d []m.PgEnumMetadata) (err error) {
+
+	// As with types, the enums are keyed on the type name only
+	seen := make(map[string]int)
+
+	for _, f := range d {
+
+		if len(f.Labels) == 0 {
+			continue
+		}
+
+		// ensure the enum has not been generated already
+		_, ok := seen[f.GoTypeName]
+		if ok {
+			continue
+		}
+		seen[f.GoTypeName] = 1
+
+		cb := u.NewLineBuf()
+
+		genEnum(args, f, cb)
+
+		writeCode(args, f.GoTypeName, fmt.Sprintf("%s.%s", f.SchemaName, f.ObjName), cb)
+	}
+
+	return
+}
+
+func maxStringLen(s string, sz int) int {
+	if len(s) > sz {
+		return len(s)
+	}
+	return sz
+}
+
+// enumConstNames returns the names of the constants for the labels of an enum
+func enumConstNames(f m.PgEnumMetadata) (names []string) {
+
+	seen := make(map[string]int)
+
+	for i, label := range f.Labels {
+		s := u.ToUpperCamelCase(strings.Trim(reNonIdent.ReplaceAllString(label, "_"), "_"))
+		if s == "" {
+			s = fmt.Sprintf("Value%d", i+1)
+		}
+		n := f.GoTypeName + s
+
+		// labels that only differ in punctuation will collide
+		if _, ok := seen[n]; ok {
+			n = fmt.Sprintf("%s%d", n, i+1)
+		}
+		seen[n] = 1
+
+		names = append(names, n)
+	}
+	return
+}
+
+func genEnum(args cArgs, f m.PgEnumMetadata, cb *u.LineBuf) {
+
+	names := enumConstNames(f)
+	rn := receiverName(f.GoTypeName)
+
+	cb.Append(fmt.Sprintf("// %s type for the %s.%s enum", f.GoTypeName, f.SchemaName, f.ObjName))
+	if f.Description != "" {
+		cb.Append(fmt.Sprintf("// %s", strings.ReplaceAll(f.Description, "\n", "\n// ")))
+	}
+	cb.Append(fmt.Sprintf("type %s string", f.GoTypeName))
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// The %s.%s enum labels, in sort order", f.SchemaName, f.ObjName))
+	var maxLen int
+	for _, n := range names {
+		maxLen = maxStringLen(n, maxLen)
+	}
+	cb.Append("const (")
+	for i, label := range f.Labels {
+		cb.Append(fmt.Sprintf("\t%s %s = %q", u.Lpad(names[i], maxLen), f.GoTypeName, label))
+	}
+	cb.Append(")")
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// All returns all of the %s values, in sort order", f.GoTypeName))
+	cb.Append(fmt.Sprintf("func (%s) All() []%s {", f.GoTypeName, f.GoTypeName))
+	cb.Append(fmt.Sprintf("\treturn []%s{", f.GoTypeName))
+	for _, n := range names {
+		cb.Append(fmt.Sprintf("\t\t%s,", n))
+	}
+	cb.Append("\t}")
+	cb.Append("}")
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// IsValid returns true if the %s is one of the %s.%s enum labels", f.GoTypeName, f.SchemaName, f.ObjName))
+	cb.Append(fmt.Sprintf("func (%s %s) IsValid() bool {", rn, f.GoTypeName))
+	cb.Append(fmt.Sprintf("\tswitch %s {", rn))
+	cb.Append(fmt.Sprintf("\tcase %s:", strings.Join(names, ", ")))
+	cb.Append("\t\treturn true")
+	cb.Append("\t}")
+	cb.Append("\treturn false")
+	cb.Append("}")
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// Scan implements the sql.Scanner interface for the %s. A NULL", f.GoTypeName))
+	cb.Append("// scans as the zero value. Any label is accepted, as labels may be added")
+	cb.Append("// to the enum after the code is generated, so use IsValid to check it.")
+	cb.Append(fmt.Sprintf("func (%s *%s) Scan(src interface{}) error {", rn, f.GoTypeName))
+	cb.Append("\tswitch v := src.(type) {")
+	cb.Append("\tcase nil:")
+	cb.Append(fmt.Sprintf("\t\t*%s = \"\"", rn))
+	cb.Append("\t\treturn nil")
+	cb.Append("\tcase string:")
+	cb.Append(fmt.Sprintf("\t\t*%s = %s(v)", rn, f.GoTypeName))
+	cb.Append("\tcase []byte:")
+	cb.Append(fmt.Sprintf("\t\t*%s = %s(v)", rn, f.GoTypeName))
+	cb.Append("\tdefault:")
+	cb.Append(fmt.Sprintf("\t\treturn fmt.Errorf(\"cannot scan %%T into %s\", src)", f.GoTypeName))
+	cb.Append("\t}")
+	cb.Append("\treturn nil")
+	cb.Append("}")
+	cb.Append("")
+
+	cb.Append(fmt.Sprintf("// Value implements the driver.Valuer interface for the %s. The", f.GoTypeName))
+	cb.Append("// zero value is written as NULL, so an empty label can not be written.")
+	cb.Append("// Any other label is written as is, as with Scan, so use IsValid to check it.")
+	cb.Append(fmt.Sprintf("func (%s %s) Value() (driver.Value, error) {", rn, f.GoTypeName))
+	cb.Append(fmt.Sprintf("\tif %s == \"\" {", rn))
+	cb.Append("\t\treturn nil, nil")
+	cb.Append("\t}")
+	cb.Append(fmt.Sprintf("\treturn string(%s), nil", rn))
+	cb.Append("}")
+	cb.Append("")
+}
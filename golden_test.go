@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	m "github.com/gsiems/pg2go/meta"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestGolden generates the code for each of the metadata snapshots in
+// testdata/fixtures and compares it to the files in testdata/golden. Run
+// with -update to rewrite the golden files after an intended change.
+func TestGolden(t *testing.T) {
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".json")
+		t.Run(name, func(t *testing.T) {
+			got := generateFixture(t, fixture)
+			dir := filepath.Join("testdata", "golden", name)
+			if *update {
+				updateGolden(t, dir, got)
+				return
+			}
+			compareGolden(t, dir, got)
+		})
+	}
+}
+
+// fixtureNullStyles are the null styles for the fixtures that are not
+// generated with the pgtype types
+var fixtureNullStyles = map[string]string{
+	"nonulls": m.NullStyleNone,
+}
+
+// generateFixture generates the code for a metadata snapshot and returns
+// the generated source by file name
+func generateFixture(t *testing.T, fixture string) map[string][]byte {
+
+	snap, err := m.ReadSnapshot(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	style, ok := fixtureNullStyles[strings.TrimSuffix(filepath.Base(fixture), ".json")]
+	if !ok {
+		style = m.NullStylePgtype
+	}
+	err = m.SetNullStyle(style)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.SetNullStyle(m.NullStylePgtype)
+
+	md, err := m.GetSnapshot(m.NewMemCatalog(snap), snap.Schema, snap.Objects, snap.AppUser, snap.PgVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := cArgs{
+		packageName: "model",
+		dbHost:      snap.DbHost,
+		dbName:      snap.DbName,
+		dbPosition:  snap.Position,
+		schemaName:  snap.Schema,
+		objName:     snap.Objects,
+		appUser:     snap.AppUser,
+		verify:      true,
+	}
+
+	pendingFiles = nil
+	defer func() { pendingFiles = nil }()
+
+	genQuerierCode(args)
+	if err = genEnumCode(args, md.Enums); err != nil {
+		t.Fatal(err)
+	}
+	if err = genDomainCode(args, md.Domains); err != nil {
+		t.Fatal(err)
+	}
+	if err = genTypeCode(args, md.Types); err != nil {
+		t.Fatal(err)
+	}
+	tables := generatedTables(md.Tables)
+	if err = genTableCode(args, tables); err != nil {
+		t.Fatal(err)
+	}
+	if err = genFunctionCode(args, md.Functions, rowStructs(tables, md.Types)); err != nil {
+		t.Fatal(err)
+	}
+	genPolymorphicSupportCode(args, md, tables)
+	genCursorSupportCode(args, md.Functions)
+	genInsertSupportCode(args, tables)
+
+	for _, msg := range verifyFiles(args, pendingFiles) {
+		t.Error(msg)
+	}
+
+	got := make(map[string][]byte)
+	for _, f := range pendingFiles {
+		got[f.fileName+".go.golden"] = f.src
+	}
+	return got
+}
+
+// updateGolden replaces the golden files in dir with the generated files
+func updateGolden(t *testing.T, dir string, got map[string][]byte) {
+
+	err := os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for fileName, src := range got {
+		err = os.WriteFile(filepath.Join(dir, fileName), src, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// compareGolden compares the generated files with the golden files in
+// dir, reporting the missing and unexpected files and the first line
+// that differs for each file
+func compareGolden(t *testing.T, dir string, got map[string][]byte) {
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := make(map[string][]byte)
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[filepath.Base(p)] = b
+	}
+
+	var fileNames []string
+	for fileName := range want {
+		fileNames = append(fileNames, fileName)
+	}
+	for fileName := range got {
+		if _, ok := want[fileName]; !ok {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		w, inWant := want[fileName]
+		g, inGot := got[fileName]
+		switch {
+		case !inGot:
+			t.Errorf("%s: file was not generated", fileName)
+		case !inWant:
+			t.Errorf("%s: unexpected file was generated (run with -update to accept it)", fileName)
+		case !bytes.Equal(w, g):
+			line, wl, gl := firstDiff(w, g)
+			t.Errorf("%s:%d: generated code differs from the golden file (run with -update to accept it)\nwant: %q\n got: %q", fileName, line, wl, gl)
+		}
+	}
+}
+
+// firstDiff returns the line number and content of the first line that
+// differs between two files
+func firstDiff(w, g []byte) (line int, wl, gl string) {
+
+	wa := strings.Split(string(w), "\n")
+	ga := strings.Split(string(g), "\n")
+
+	for i := 0; i < len(wa) || i < len(ga); i++ {
+		wl, gl = "", ""
+		if i < len(wa) {
+			wl = wa[i]
+		}
+		if i < len(ga) {
+			gl = ga[i]
+		}
+		if wl != gl || i >= len(wa) || i >= len(ga) {
+			return i + 1, wl, gl
+		}
+	}
+	return 0, "", ""
+}
+
+// TestFunctionNameCollision checks that two functions configured with
+// the same Go name are reported rather than one overwriting the other
+func TestFunctionNameCollision(t *testing.T) {
+
+	snap, err := m.ReadSnapshot(filepath.Join("testdata", "fixtures", "functions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.SetFunctionNames(map[string]string{
+		"app.find_customers(text)":              "FindCustomers",
+		"app.find_customers(character varying)": "FindCustomers",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.SetFunctionNames(nil)
+
+	_, err = m.GetSnapshot(m.NewMemCatalog(snap), snap.Schema, snap.Objects, snap.AppUser, snap.PgVersion)
+	if err == nil || !strings.Contains(err.Error(), "app.find_customers(text)") || !strings.Contains(err.Error(), "app.find_customers(character varying)") {
+		t.Errorf("expected an error naming both functions, got %v", err)
+	}
+}
+
+// TestFunctionTypeNameCollision checks that a function configured with
+// the name of a generated table struct is reported
+func TestFunctionTypeNameCollision(t *testing.T) {
+
+	snap, err := m.ReadSnapshot(filepath.Join("testdata", "fixtures", "tables.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.SetFunctionNames(map[string]string{
+		"app.customer_orders": "Customer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.SetFunctionNames(nil)
+
+	_, err = m.GetSnapshot(m.NewMemCatalog(snap), snap.Schema, snap.Objects, snap.AppUser, snap.PgVersion)
+	if err == nil || !strings.Contains(err.Error(), "app.customer_orders(") || !strings.Contains(err.Error(), "app.customer table") {
+		t.Errorf("expected an error naming the function and the table, got %v", err)
+	}
+}
+
+// countingCatalog counts the ListTableColumns calls, and records the
+// schemas that they were made for
+type countingCatalog struct {
+	m.Catalog
+	tableColumnCalls   int
+	tableColumnSchemas []string
+}
+
+func (c *countingCatalog) ListTableColumns(schema, objName, user string, pgVersion int) ([]m.PgColumnMetadata, error) {
+	c.tableColumnCalls++
+	c.tableColumnSchemas = append(c.tableColumnSchemas, schema)
+	return c.Catalog.ListTableColumns(schema, objName, user, pgVersion)
+}
+
+// TestRowTypeColumnsBatched checks that the columns of the tables
+// returned by functions are read in one call rather than per function
+func TestRowTypeColumnsBatched(t *testing.T) {
+
+	snap, err := m.ReadSnapshot(filepath.Join("testdata", "fixtures", "tables.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range snap.Functions {
+		if f.ObjName == "recent_customers" {
+			f.ObjName = "recent_customers_too"
+			snap.Functions = append(snap.Functions, f)
+			break
+		}
+	}
+
+	cat := &countingCatalog{Catalog: m.NewMemCatalog(snap)}
+	md, err := m.GetSnapshot(cat, snap.Schema, snap.Objects, snap.AppUser, snap.PgVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// once for the tables and once for the function result types
+	if cat.tableColumnCalls != 2 {
+		t.Errorf("expected 2 ListTableColumns calls, got %d", cat.tableColumnCalls)
+	}
+	for _, f := range md.Functions {
+		if strings.HasPrefix(f.ObjName, "recent_customers") && len(f.ResultRowColumns) == 0 {
+			t.Errorf("expected the %s result row columns", f.ObjName)
+		}
+	}
+}
+
+// TestRowTypeColumnsBySchema checks that the columns of a table returned
+// by a function are read by the schema of the table, so that a table of
+// the same name in another schema (or one that is not in the search
+// path) is not used
+func TestRowTypeColumnsBySchema(t *testing.T) {
+
+	snap, err := m.ReadSnapshot(filepath.Join("testdata", "fixtures", "tables.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tab := range snap.Tables {
+		if tab.ObjName == "customer" {
+			tab.SchemaName = "archive"
+			tab.Columns = append([]m.PgColumnMetadata(nil), tab.Columns[:1]...)
+			snap.Tables = append(snap.Tables, tab)
+			break
+		}
+	}
+	snap.OidTypes = append(snap.OidTypes, m.PgOidTypeMetadata{Oid: 16500, SchemaName: "archive", TypeName: "customer", DataType: "archive.customer", TypeType: "c", TypeCategory: "C"})
+	for _, f := range snap.Functions {
+		if f.ObjName == "recent_customers" {
+			f.ObjName = "archived_customers"
+			f.ArgTypes = "1184,16500"
+			snap.Functions = append(snap.Functions, f)
+			break
+		}
+	}
+
+	cat := &countingCatalog{Catalog: m.NewMemCatalog(snap)}
+	md, err := m.GetSnapshot(cat, snap.Schema, snap.Objects, snap.AppUser, snap.PgVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range md.Functions {
+		switch {
+		case f.ObjName == "recent_customers" && len(f.ResultRowColumns) <= 1:
+			t.Errorf("expected the app.customer columns for %s, got %d columns", f.ObjName, len(f.ResultRowColumns))
+		case f.ObjName == "archived_customers" && len(f.ResultRowColumns) != 1:
+			t.Errorf("expected the archive.customer columns for %s, got %d columns", f.ObjName, len(f.ResultRowColumns))
+		}
+	}
+
+	for _, schema := range cat.tableColumnSchemas[1:] {
+		if schema == "" {
+			t.Errorf("expected the function result type columns to be read by schema")
+		}
+	}
+}
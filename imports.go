@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	u "github.com/gsiems/pg2go/util"
+)
+
+// knownImports maps the package names that the generated code may refer
+// to to their import paths
+var knownImports = map[string]string{
+	"context": "context",
+	"driver":  "database/sql/driver",
+	"fmt":     "fmt",
+	"hex":     "encoding/hex",
+	"math":    "math",
+	"pgtype":  "github.com/jackc/pgtype",
+	"pq":      "github.com/lib/pq",
+	"reflect": "reflect",
+	"regexp":  "regexp",
+	"sql":     "database/sql",
+	"strconv": "strconv",
+	"strings": "strings",
+	"time":    "time",
+	"utf8":    "unicode/utf8",
+}
+
+// usedImports returns the import paths of the known packages that the
+// code refers to
+func usedImports(code string) (d []string, err error) {
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package x\n\n"+code, 0)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]int)
+	ast.Inspect(f, func(n ast.Node) bool {
+		se, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// Package names are not resolved by the parser
+		id, ok := se.X.(*ast.Ident)
+		if !ok || id.Obj != nil {
+			return true
+		}
+		p, ok := knownImports[id.Name]
+		if ok {
+			seen[p] = 1
+		}
+		return true
+	})
+
+	for p := range seen {
+		d = append(d, p)
+	}
+	return
+}
+
+// writeCode writes a generated file. The header, with the imports used
+// by the code plus any imports needed for overridden types, is prepended
+// to the code and the result is formatted before writing. When verifying,
+// the file is held for writing until all of the files have been checked.
+func writeCode(args cArgs, fileName, objName string, cb *u.LineBuf, imports ...string) {
+
+	code := cb.String()
+
+	used, err := usedImports(code)
+	if err != nil {
+		fmt.Printf("Failed to parse the generated code for %q: %s\n", fileName, err)
+	}
+
+	hb := u.NewLineBuf()
+	appendHeader(args, hb, append(used, imports...)...)
+	hb.Append(code)
+
+	src := []byte(hb.String())
+	formatted, err := format.Source(src)
+	if err != nil {
+		fmt.Printf("Failed to format the generated code for %q: %s\n", fileName, err)
+		formatted = src
+	}
+
+	if args.verify {
+		pendingFiles = append(pendingFiles, genFile{fileName: fileName, objName: objName, src: formatted})
+		return
+	}
+
+	writeFile(args, fileName, formatted)
+}
+
+// writeFile writes the source for a generated file
+func writeFile(args cArgs, fileName string, src []byte) {
+	fb := u.NewLineBuf()
+	fb.Append(string(src))
+	u.WriteFile(args.packageName, fileName, fb)
+}
+
+// groupImports returns the sorted, de-duplicated, standard library and
+// other imports
+func groupImports(imports []string) (std, other []string) {
+
+	seen := make(map[string]int)
+
+	for _, s := range imports {
+		_, ok := seen[s]
+		if ok {
+			continue
+		}
+		seen[s] = 1
+
+		if strings.Contains(strings.Split(s, "/")[0], ".") {
+			other = append(other, s)
+		} else {
+			std = append(std, s)
+		}
+	}
+
+	sort.Strings(std)
+	sort.Strings(other)
+	return
+}
@@ -29,7 +29,7 @@ func (db *DB) CloseDB() error {
 	return db.DB.Close()
 }
 
-func DbVersion(db *sql.DB) (v int, err error) {
+func DbVersion(db Queryer) (v int, err error) {
 
 	rows, err := db.Query("SELECT current_setting('server_version_num')::int")
 	if err != nil {
@@ -71,7 +71,7 @@ func makeStructStanza(col PgColumnMetadata, maxDbNameLen, maxVarNameLen, maxVarT
 	jsonName := u.ToLowerCamelCase(col.ColumnName)
 
 	var varType string
-	varType, err = TranslateType(col.TypeName)
+	varType, err = TranslateColumnType(col)
 	if err != nil {
 		err = fmt.Errorf("makeStructStanza - %s: %s", col.ColumnName, err)
 		return
@@ -96,8 +96,11 @@ func makeStructStanza(col PgColumnMetadata, maxDbNameLen, maxVarNameLen, maxVarT
 		ary = append(ary, " [Not Null]")
 	}
 
+	// A trailing comment can not span lines so the lines of the
+	// description are joined. Continuation comment lines would otherwise
+	// break the alignment of the struct fields.
 	if col.Description != "" {
-		ary = append(ary, fmt.Sprintf(" %s", strings.ReplaceAll(col.Description, "\n", "\n//                                           ")))
+		ary = append(ary, fmt.Sprintf(" %s", strings.Join(strings.Fields(col.Description), " ")))
 	}
 
 	s = strings.Join(ary, "")
@@ -112,7 +115,7 @@ func getMaxLens(cols []PgColumnMetadata) (maxDbNameLen, maxVarNameLen, maxVarTyp
 		maxVarNameLen = maxStringLen(goVarName, maxVarNameLen)
 
 		var varType string
-		varType, err = TranslateType(col.TypeName)
+		varType, err = TranslateColumnType(col)
 		if err != nil {
 			err = fmt.Errorf("getMaxLens - %s: %s", col.ColumnName, err)
 			return
@@ -0,0 +1,76 @@
+package meta
+
+import (
+	"database/sql"
+)
+
+// Catalog is the source of the database metadata that the Get*Metas
+// functions use for building the metadata for generating code. The
+// column and foreign key methods return those for all of the objects
+// matching the schema and (comma-separated) object names, with the
+// SchemaName and ObjName of each set to the owning object, so that they
+// can be read in a single query.
+type Catalog interface {
+	ListEnums(schema string) ([]PgEnumMetadata, error)
+	ListDomains(schema string) ([]PgDomainMetadata, error)
+	ListOidTypes() ([]PgOidTypeMetadata, error)
+	ListTypes(schema, objName string) ([]PgUsertypeMetadata, error)
+	ListTypeColumns(schema, objName string) ([]PgColumnMetadata, error)
+	ListTables(schema, objName, user string) ([]PgTableMetadata, error)
+	ListTableColumns(schema, objName, user string, pgVersion int) ([]PgColumnMetadata, error)
+	ListForeignKeys(schema, objName string) ([]PgForeignKeyMetadata, error)
+	ListFunctions(schema, objName, user string, pgVersion int) ([]PgFunctionMetadata, error)
+}
+
+// Queryer runs the metadata queries. It is satisfied by *sql.DB and
+// *sql.Tx.
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// PgCatalog is the Catalog for a PostgreSQL database
+type PgCatalog struct {
+	db Queryer
+}
+
+// NewPgCatalog returns the Catalog for a PostgreSQL database connection,
+// or transaction
+func NewPgCatalog(db Queryer) *PgCatalog {
+	return &PgCatalog{db: db}
+}
+
+func (c *PgCatalog) ListEnums(schema string) ([]PgEnumMetadata, error) {
+	return listEnumMetas(c.db, schema)
+}
+
+func (c *PgCatalog) ListDomains(schema string) ([]PgDomainMetadata, error) {
+	return listDomainMetas(c.db, schema)
+}
+
+func (c *PgCatalog) ListOidTypes() ([]PgOidTypeMetadata, error) {
+	return listOidTypeMetas(c.db)
+}
+
+func (c *PgCatalog) ListTypes(schema, objName string) ([]PgUsertypeMetadata, error) {
+	return listTypeMetas(c.db, schema, objName)
+}
+
+func (c *PgCatalog) ListTypeColumns(schema, objName string) ([]PgColumnMetadata, error) {
+	return listTypeColumnMetas(c.db, schema, objName)
+}
+
+func (c *PgCatalog) ListTables(schema, objName, user string) ([]PgTableMetadata, error) {
+	return listTableMetas(c.db, schema, objName, user)
+}
+
+func (c *PgCatalog) ListTableColumns(schema, objName, user string, pgVersion int) ([]PgColumnMetadata, error) {
+	return listTableColumnMetas(c.db, schema, objName, user, pgVersion)
+}
+
+func (c *PgCatalog) ListForeignKeys(schema, objName string) ([]PgForeignKeyMetadata, error) {
+	return listForeignKeyMetas(c.db, schema, objName)
+}
+
+func (c *PgCatalog) ListFunctions(schema, objName, user string, pgVersion int) ([]PgFunctionMetadata, error) {
+	return listFunctionMetas(c.db, schema, objName, user, pgVersion)
+}
@@ -2,6 +2,8 @@ package meta
 
 // PgColumnMetadata contains metadata for database columns
 type PgColumnMetadata struct {
+	SchemaName      string `db:"schema_name"`
+	ObjName         string `db:"obj_name"`
 	ColumnName      string `db:"column_name"`
 	DataType        string `db:"data_type"`
 	TypeName        string `db:"type_name"`
@@ -10,4 +12,35 @@ type PgColumnMetadata struct {
 	IsRequired      bool   `db:"is_required"`
 	IsPk            bool   `db:"is_pk"`
 	Description     string `db:"description"`
+	Default         string `db:"default_value" json:",omitempty"` // the default expression for function arguments
+	ArgMode         string `db:"arg_mode" json:",omitempty"`      // the mode (i, o, b, v, or t) of function arguments
+	Identity        string `db:"identity" json:",omitempty"`      // the identity (a for always, d for by default) of table columns
+	Generated       string `db:"generated" json:",omitempty"`     // the generation (s for stored) of table columns
+	HasDefault      bool   `db:"has_default" json:",omitempty"`   // whether a table column has a default value
+	Privileges      PgPrivileges
+}
+
+// setColumnOwner sets the schema and name of the object that the
+// columns belong to
+func setColumnOwner(cols []PgColumnMetadata, schemaName, objName string) {
+	for i := range cols {
+		cols[i].SchemaName = schemaName
+		cols[i].ObjName = objName
+	}
+}
+
+// objKey returns the key for looking up the columns, or foreign keys, of
+// a database object
+func objKey(schemaName, objName string) string {
+	return schemaName + "." + objName
+}
+
+// groupColumns groups the columns by the object that they belong to
+func groupColumns(cols []PgColumnMetadata) map[string][]PgColumnMetadata {
+	d := make(map[string][]PgColumnMetadata)
+	for _, col := range cols {
+		k := objKey(col.SchemaName, col.ObjName)
+		d[k] = append(d[k], col)
+	}
+	return d
 }
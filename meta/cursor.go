@@ -0,0 +1,101 @@
+package meta
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cursorAnnotation is the prefix of the line, in the comment on a
+// function, that declares the row types of the refcursors that the
+// function returns (as in "pg2go:cursors app.customer, app.orders")
+const cursorAnnotation = "pg2go:cursors"
+
+// SetFunctionCursors sets the row types of the refcursors returned by
+// functions, keyed as for SetFunctionNames. Each row type is the name of
+// a table, view, or composite type, which defaults to the schema of the
+// function when not schema qualified, and may be preceded by a name for
+// the cursor (as in "open_orders=app.orders").
+func SetFunctionCursors(cursors map[string][]string) error {
+	for k, v := range cursors {
+		if len(v) == 0 {
+			return fmt.Errorf("No cursor row types specified for the %q function", k)
+		}
+	}
+	tc.functionCursors = cursors
+	return nil
+}
+
+// setFunctionCursors sets the declared row types of the refcursors for
+// the functions that return only refcursors. Row types from
+// SetFunctionCursors take precedence over those from the comment on the
+// function.
+func setFunctionCursors(funcs []PgFunctionMetadata) {
+
+	for i, f := range funcs {
+
+		funcs[i].Cursors = nil
+		funcs[i].CursorNames = nil
+		if !returnsCursors(f) {
+			continue
+		}
+
+		names, ok := tc.functionCursors[functionSignature(f)]
+		if !ok {
+			names, ok = tc.functionCursors[objKey(f.SchemaName, f.ObjName)]
+		}
+		if !ok {
+			names = cursorComment(f.Description)
+		}
+
+		named := false
+		var cursorNames []string
+		for _, n := range names {
+			var name string
+			if j := strings.Index(n, "="); j >= 0 {
+				name = strings.TrimSpace(n[:j])
+				n = n[j+1:]
+				named = named || name != ""
+			}
+			n = strings.TrimSpace(n)
+			if !strings.Contains(n, ".") {
+				n = objKey(f.SchemaName, n)
+			}
+			funcs[i].Cursors = append(funcs[i].Cursors, n)
+			cursorNames = append(cursorNames, name)
+		}
+		if named {
+			funcs[i].CursorNames = cursorNames
+		}
+	}
+}
+
+// returnsCursors returns true if the results of a function are all
+// refcursors
+func returnsCursors(f PgFunctionMetadata) bool {
+	if len(f.ResultColumns) == 0 {
+		return false
+	}
+	for _, col := range f.ResultColumns {
+		if col.TypeName != "refcursor" {
+			return false
+		}
+	}
+	return true
+}
+
+// cursorComment returns the cursor row types declared in the comment on
+// a function, if any
+func cursorComment(description string) (d []string) {
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, cursorAnnotation) {
+			continue
+		}
+		for _, n := range strings.Split(strings.TrimPrefix(line, cursorAnnotation), ",") {
+			if strings.TrimSpace(n) != "" {
+				d = append(d, n)
+			}
+		}
+	}
+	return
+}
@@ -1,31 +1,89 @@
 package meta
 
 import (
-	"database/sql"
+	"fmt"
+	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	u "github.com/gsiems/pg2go/util"
 )
 
-// PgColumnMetadata contains metadata for domains
+// PgDomainMetadata contains metadata for domains
 type PgDomainMetadata struct {
-	SchemaName   string `db:"schema_name"`
-	ObjName      string `db:"obj_name"`
-	DataType     string `db:"data_type"`
-	TypeName     string `db:"type_name"`
-	TypeCategory string `db:"type_category"`
-	IsRequired   bool   `db:"is_required"`
-	Description  string `db:"description"`
+	SchemaName   string   `db:"schema_name"`
+	ObjName      string   `db:"obj_name"`
+	DataType     string   `db:"data_type"`
+	TypeName     string   `db:"type_name"`
+	TypeCategory string   `db:"type_category"`
+	IsRequired   bool     `db:"is_required"`
+	Description  string   `db:"description"`
+	Checks       []string `db:"checks"`
+	GoTypeName   string
 }
 
-// GetDomainMetas returns the metadata for the avaiable domains
-func GetDomainMetas(db *sql.DB, schema, objName, user string, pgVersion int) (d []PgDomainMetadata, err error) {
+// GetDomainMetas returns the metadata for the avaiable domains. As the
+// domains are needed for translating the column and argument types of
+// the other objects they are not filtered by object name, and all of the
+// domains are loaded into the translator so that the domains from other
+// schemas can still be translated (see addReferencedDomains).
+func GetDomainMetas(cat Catalog, schema, objName, user string, pgVersion int) (d []PgDomainMetadata, err error) {
+
+	all, errq := cat.ListDomains("")
+	if errq != nil {
+		err = fmt.Errorf("Expected domain metadata, got error: %q", errq)
+		return
+	}
+	for i, v := range all {
+		all[i].GoTypeName = u.ToUpperCamelCase(v.ObjName)
+		addUserDomain(&all[i])
+	}
+
+	for _, v := range all {
+		if inSchema(v.SchemaName, schema) {
+			d = append(d, v)
+		}
+	}
+	return
+}
 
-	var u PgDomainMetadata
+// addReferencedDomains adds the domains that are used by the columns of
+// the types, tables, and functions, or that are the base type of another
+// domain, but that were not selected by the schema name, to the domains
+// so that the types for them are generated.
+func addReferencedDomains(domains []PgDomainMetadata, cols []PgColumnMetadata) []PgDomainMetadata {
+
+	seen := make(map[string]bool)
+	var typeNames []string
+	for _, v := range domains {
+		seen[v.ObjName] = true
+		typeNames = append(typeNames, v.TypeName)
+	}
+	for _, col := range cols {
+		typeNames = append(typeNames, col.TypeName)
+	}
+
+	// The base types of the added domains are checked in turn so that
+	// domains over domains are added
+	for i := 0; i < len(typeNames); i++ {
+		typeName := strings.TrimPrefix(typeNames[i], "_")
+		v, ok := tc.domains[typeName]
+		if !ok || seen[typeName] {
+			continue
+		}
+		seen[typeName] = true
+		domains = append(domains, *v)
+		typeNames = append(typeNames, v.TypeName)
+	}
+	return domains
+}
+
+// listDomainMetas returns the list of avaiable domains
+func listDomainMetas(db Queryer, schema string) (d []PgDomainMetadata, err error) {
 
 	q := `
 WITH args AS (
-    SELECT $1 AS schema_name,
-            regexp_split_to_table ( $2, ', *' ) AS obj_name
+    SELECT $1 AS schema_name
 )
 SELECT n.nspname::text AS schema_name,
         t.typname::text AS obj_name,
@@ -33,7 +91,14 @@ SELECT n.nspname::text AS schema_name,
         tc.typname AS type_name,
         tc.typcategory AS type_category,
         t.typnotnull AS is_required,
-        coalesce ( d.description, '' ) AS description
+        coalesce ( d.description, '' ) AS description,
+        ARRAY (
+            SELECT pg_catalog.pg_get_constraintdef ( c.oid )
+                FROM pg_catalog.pg_constraint c
+                WHERE c.contypid = t.oid
+                    AND c.contype = 'c'
+                ORDER BY c.conname
+            ) AS checks
     FROM pg_catalog.pg_type t
     JOIN pg_catalog.pg_namespace n
         ON ( n.oid = t.typnamespace )
@@ -50,33 +115,33 @@ SELECT n.nspname::text AS schema_name,
         AND n.nspname !~ '^pg_toast'
         AND ( n.nspname::text = args.schema_name
             OR args.schema_name = '' )
-        AND ( t.typname::text = args.obj_name
-            OR coalesce ( args.obj_name, '' ) = '' )
+    ORDER BY n.nspname,
+        t.typname
 `
 
-	rows, err := db.Query(q, schema, objName)
+	rows, err := db.Query(q, schema)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
 	for rows.Next() {
+		var v PgDomainMetadata
 
-		err = rows.Scan(&u.SchemaName,
-			&u.ObjName,
-			&u.DataType,
-			&u.TypeName,
-			&u.TypeCategory,
-			&u.IsRequired,
-			&u.Description,
+		err = rows.Scan(&v.SchemaName,
+			&v.ObjName,
+			&v.DataType,
+			&v.TypeName,
+			&v.TypeCategory,
+			&v.IsRequired,
+			&v.Description,
+			pq.Array(&v.Checks),
 		)
 		if err != nil {
 			return
 		}
 
-		d = append(d, u)
-
-		addUserDomain(u.ObjName, u.TypeName)
+		d = append(d, v)
 	}
 
 	return
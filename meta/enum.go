@@ -0,0 +1,129 @@
+package meta
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	u "github.com/gsiems/pg2go/util"
+)
+
+// PgEnumMetadata contains metadata for postgresql enum types
+type PgEnumMetadata struct {
+	SchemaName  string   `db:"schema_name"`
+	ObjName     string   `db:"obj_name"`
+	Description string   `db:"description"`
+	Labels      []string `db:"labels"`
+	GoTypeName  string
+}
+
+// GetEnumMetas returns the metadata for the avaiable enum types. As the
+// enums are needed for translating the column and argument types of the
+// other objects they are not filtered by object name, and all of the
+// enums are loaded into the translator so that the enums from other
+// schemas can still be translated (see addReferencedEnums).
+func GetEnumMetas(cat Catalog, schema, objName, user string, pgVersion int) (d []PgEnumMetadata, err error) {
+
+	all, errq := cat.ListEnums("")
+	if errq != nil {
+		err = fmt.Errorf("Expected enum metadata, got error: %q", errq)
+		return
+	}
+	for i, e := range all {
+		all[i].GoTypeName = u.ToUpperCamelCase(e.ObjName)
+		addUserEnum(&all[i])
+	}
+
+	for _, e := range all {
+		if inSchema(e.SchemaName, schema) {
+			d = append(d, e)
+		}
+	}
+	return
+}
+
+// addReferencedEnums adds the enums that are used by the columns of the
+// types, tables, and functions, or that are the base type of a domain,
+// but that were not selected by the schema name, to the enums so that
+// the types for them are generated.
+func addReferencedEnums(enums []PgEnumMetadata, domains []PgDomainMetadata, cols []PgColumnMetadata) []PgEnumMetadata {
+
+	seen := make(map[string]bool)
+	for _, e := range enums {
+		seen[e.ObjName] = true
+	}
+
+	var typeNames []string
+	for _, v := range domains {
+		typeNames = append(typeNames, v.TypeName)
+	}
+	for _, col := range cols {
+		typeNames = append(typeNames, col.TypeName)
+	}
+
+	for _, typeName := range typeNames {
+		typeName = strings.TrimPrefix(typeName, "_")
+		e, ok := tc.enums[typeName]
+		if !ok || seen[typeName] {
+			continue
+		}
+		seen[typeName] = true
+		enums = append(enums, *e)
+	}
+	return enums
+}
+
+// listEnumMetas returns the list of avaiable enum types
+func listEnumMetas(db Queryer, schema string) (d []PgEnumMetadata, err error) {
+
+	q := `
+WITH args AS (
+    SELECT $1 AS schema_name
+)
+SELECT n.nspname::text AS schema_name,
+        t.typname::text AS obj_name,
+        coalesce ( pg_catalog.obj_description ( t.oid, 'pg_type' ), '' ) AS description,
+        array_agg ( e.enumlabel::text ORDER BY e.enumsortorder ) AS labels
+    FROM pg_catalog.pg_type t
+    JOIN pg_catalog.pg_namespace n
+        ON ( n.oid = t.typnamespace )
+    JOIN pg_catalog.pg_enum e
+        ON ( e.enumtypid = t.oid )
+    CROSS JOIN args
+    WHERE t.typtype = 'e'
+        AND n.nspname <> 'pg_catalog'
+        AND n.nspname <> 'information_schema'
+        AND n.nspname !~ '^pg_toast'
+        AND ( n.nspname::text = args.schema_name
+            OR args.schema_name = '' )
+    GROUP BY n.nspname,
+        t.typname,
+        t.oid
+    ORDER BY n.nspname,
+        t.typname
+`
+
+	rows, err := db.Query(q, schema)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e PgEnumMetadata
+
+		err = rows.Scan(&e.SchemaName,
+			&e.ObjName,
+			&e.Description,
+			pq.Array(&e.Labels),
+		)
+		if err != nil {
+			return
+		}
+
+		d = append(d, e)
+	}
+
+	return
+}
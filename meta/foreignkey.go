@@ -0,0 +1,122 @@
+package meta
+
+import (
+	"github.com/lib/pq"
+)
+
+// PgForeignKeyMetadata contains metadata for foreign keys
+type PgForeignKeyMetadata struct {
+	SchemaName     string   `db:"schema_name"`
+	ObjName        string   `db:"obj_name"`
+	ConstraintName string   `db:"constraint_name"`
+	Columns        []string `db:"column_names"`
+	RefSchemaName  string   `db:"ref_schema_name"`
+	RefObjName     string   `db:"ref_obj_name"`
+	RefColumns     []string `db:"ref_column_names"`
+	OnUpdate       string   `db:"on_update"`
+	OnDelete       string   `db:"on_delete"`
+}
+
+// groupForeignKeys groups the foreign keys by the table that they belong to
+func groupForeignKeys(fks []PgForeignKeyMetadata) map[string][]PgForeignKeyMetadata {
+	d := make(map[string][]PgForeignKeyMetadata)
+	for _, fk := range fks {
+		k := objKey(fk.SchemaName, fk.ObjName)
+		d[k] = append(d[k], fk)
+	}
+	return d
+}
+
+// listForeignKeyMetas returns the metadata for the foreign keys of the
+// tables
+func listForeignKeyMetas(db Queryer, schema, objName string) (d []PgForeignKeyMetadata, err error) {
+
+	q := `
+WITH args AS (
+    SELECT $1 AS schema_name,
+            regexp_split_to_table ( $2, ', *' ) AS obj_name
+)
+SELECT n.nspname::text AS schema_name,
+        c.relname::text AS obj_name,
+        con.conname::text AS constraint_name,
+        array_agg ( la.attname::text ORDER BY k.ordinal_position ) AS column_names,
+        rn.nspname::text AS ref_schema_name,
+        rc.relname::text AS ref_obj_name,
+        array_agg ( ra.attname::text ORDER BY k.ordinal_position ) AS ref_column_names,
+        CASE con.confupdtype
+            WHEN 'a' THEN 'NO ACTION'
+            WHEN 'r' THEN 'RESTRICT'
+            WHEN 'c' THEN 'CASCADE'
+            WHEN 'n' THEN 'SET NULL'
+            WHEN 'd' THEN 'SET DEFAULT'
+            END AS on_update,
+        CASE con.confdeltype
+            WHEN 'a' THEN 'NO ACTION'
+            WHEN 'r' THEN 'RESTRICT'
+            WHEN 'c' THEN 'CASCADE'
+            WHEN 'n' THEN 'SET NULL'
+            WHEN 'd' THEN 'SET DEFAULT'
+            END AS on_delete
+    FROM pg_catalog.pg_constraint con
+    JOIN pg_catalog.pg_class c
+        ON ( c.oid = con.conrelid )
+    JOIN pg_catalog.pg_namespace n
+        ON ( n.oid = c.relnamespace )
+    JOIN pg_catalog.pg_class rc
+        ON ( rc.oid = con.confrelid )
+    JOIN pg_catalog.pg_namespace rn
+        ON ( rn.oid = rc.relnamespace )
+    CROSS JOIN LATERAL unnest ( con.conkey, con.confkey )
+        WITH ORDINALITY AS k ( attnum, ref_attnum, ordinal_position )
+    JOIN pg_catalog.pg_attribute la
+        ON ( la.attrelid = con.conrelid
+            AND la.attnum = k.attnum )
+    JOIN pg_catalog.pg_attribute ra
+        ON ( ra.attrelid = con.confrelid
+            AND ra.attnum = k.ref_attnum )
+    CROSS JOIN args
+    WHERE con.contype = 'f'
+        AND ( n.nspname = args.schema_name
+            OR args.schema_name = '' )
+        AND ( c.relname = args.obj_name
+            OR coalesce ( args.obj_name, '' ) = '' )
+    GROUP BY n.nspname,
+        c.relname,
+        con.conname,
+        rn.nspname,
+        rc.relname,
+        con.confupdtype,
+        con.confdeltype
+    ORDER BY n.nspname,
+        c.relname,
+        con.conname
+`
+
+	rows, err := db.Query(q, schema, objName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u PgForeignKeyMetadata
+
+		err = rows.Scan(&u.SchemaName,
+			&u.ObjName,
+			&u.ConstraintName,
+			pq.Array(&u.Columns),
+			&u.RefSchemaName,
+			&u.RefObjName,
+			pq.Array(&u.RefColumns),
+			&u.OnUpdate,
+			&u.OnDelete,
+		)
+		if err != nil {
+			return
+		}
+
+		d = append(d, u)
+	}
+
+	return
+}
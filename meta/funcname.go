@@ -0,0 +1,170 @@
+package meta
+
+import (
+	"fmt"
+	"strings"
+
+	u "github.com/gsiems/pg2go/util"
+)
+
+// SetFunctionNames sets the explicit Go names for functions. A name is
+// keyed by schema.function(argument types), using the formatted types of
+// the input arguments (as in "app.find_orders(integer, date)"), to name
+// one overload of a function, or by schema.function to name a function
+// that is not overloaded.
+func SetFunctionNames(names map[string]string) error {
+	for k, v := range names {
+		if v == "" {
+			return fmt.Errorf("No Go name specified for the %q function", k)
+		}
+	}
+	tc.functionNames = names
+	return nil
+}
+
+// functionSignature returns the key for looking up the explicit name of
+// a function overload
+func functionSignature(f PgFunctionMetadata) string {
+	var ary []string
+	for _, a := range f.CallingArguments {
+		ary = append(ary, a.DataType)
+	}
+	return fmt.Sprintf("%s.%s(%s)", f.SchemaName, f.ObjName, strings.Join(ary, ", "))
+}
+
+// setFunctionNames sets the Go function and result struct names for the
+// functions. Each overload of an overloaded function gets a distinct name
+// by appending "By" and the names of its input arguments, or the types of
+// the arguments when the names are missing or are not distinct. An
+// overload with no input arguments keeps the name of the function.
+// Explicit names from SetFunctionNames take precedence. Two functions
+// that end up with the same Go name are an error (as is a function named
+// the same as a generated type, see checkFunctionNames).
+func setFunctionNames(funcs []PgFunctionMetadata) (err error) {
+
+	overloads := make(map[string][]int)
+	for i, f := range funcs {
+		k := objKey(f.SchemaName, f.ObjName)
+		overloads[k] = append(overloads[k], i)
+	}
+
+	for _, idx := range overloads {
+
+		suffixes := make([]string, len(idx))
+		if len(idx) > 1 {
+			count := make(map[string]int)
+			for j, i := range idx {
+				suffixes[j] = argNamesSuffix(funcs[i])
+				if suffixes[j] == "" && len(funcs[i].CallingArguments) > 0 {
+					suffixes[j] = argTypesSuffix(funcs[i])
+				}
+				count[suffixes[j]]++
+			}
+			for j, i := range idx {
+				if count[suffixes[j]] > 1 && len(funcs[i].CallingArguments) > 0 {
+					suffixes[j] = argTypesSuffix(funcs[i])
+				}
+			}
+		}
+
+		for j, i := range idx {
+			f := funcs[i]
+			name := u.ToUpperCamelCase(f.ObjName) + suffixes[j]
+
+			if n, ok := tc.functionNames[functionSignature(f)]; ok {
+				name = n
+			} else if n, ok := tc.functionNames[objKey(f.SchemaName, f.ObjName)]; ok && len(idx) == 1 {
+				name = n
+			}
+
+			funcs[i].FuncName = name
+			funcs[i].StructName = name + "Result"
+			funcs[i].Overloaded = len(idx) > 1
+		}
+	}
+
+	named := make(map[string]int)
+	for i, f := range funcs {
+		j, ok := named[f.FuncName]
+		if ok {
+			err = fmt.Errorf("The %q and %q functions are both named %q, set distinct names for them in the configuration", functionSignature(funcs[j]), functionSignature(f), f.FuncName)
+			return
+		}
+		named[f.FuncName] = i
+	}
+	return
+}
+
+// checkFunctionNames checks that the Go function and result struct names
+// of the functions do not collide with the names of the structs and types
+// that are generated for the tables, views, enums, domains, and composite
+// types. The result struct name is not checked for a function that
+// returns the rows of a table, view, or type with a generated struct.
+func checkFunctionNames(s Snapshot) (err error) {
+
+	typeNames := make(map[string]string)
+	rowTypes := make(map[string]bool)
+	for _, f := range s.Enums {
+		typeNames[f.GoTypeName] = fmt.Sprintf("%s.%s enum", f.SchemaName, f.ObjName)
+	}
+	for _, f := range s.Domains {
+		typeNames[f.GoTypeName] = fmt.Sprintf("%s.%s domain", f.SchemaName, f.ObjName)
+	}
+	for _, f := range s.Types {
+		typeNames[f.StructName] = fmt.Sprintf("%s.%s type", f.SchemaName, f.ObjName)
+		typeNames[f.StructName+"Array"] = fmt.Sprintf("%s.%s type array", f.SchemaName, f.ObjName)
+		rowTypes[objKey(f.SchemaName, f.TypeName)] = true
+	}
+	for _, f := range s.Tables {
+		typeNames[f.StructName] = fmt.Sprintf("%s.%s %s", f.SchemaName, f.ObjName, f.ObjType)
+		rowTypes[objKey(f.SchemaName, f.ObjName)] = true
+	}
+
+	for _, f := range s.Functions {
+		names := []string{f.FuncName}
+		if !rowTypes[f.ResultRowType] {
+			names = append(names, f.StructName)
+		}
+		for _, name := range names {
+			if obj, ok := typeNames[name]; ok {
+				err = fmt.Errorf("The %q function and the %s are both named %q, set a distinct name for the function in the configuration", functionSignature(f), obj, name)
+				return
+			}
+		}
+	}
+	return
+}
+
+// argNamesSuffix returns the "By" suffix from the input argument names,
+// or an empty string if any of the arguments are not named. Any "p_"
+// parameter prefix is not included.
+func argNamesSuffix(f PgFunctionMetadata) string {
+
+	if len(f.CallingArguments) == 0 {
+		return ""
+	}
+
+	var ary []string
+	for _, a := range f.CallingArguments {
+		name := strings.TrimPrefix(strings.TrimLeft(a.ColumnName, "_"), "p_")
+		if name == "" || !ArgIsNamed(f, a) {
+			return ""
+		}
+		ary = append(ary, u.ToUpperCamelCase(name))
+	}
+	return "By" + strings.Join(ary, "")
+}
+
+// argTypesSuffix returns the "By" suffix from the input argument types
+func argTypesSuffix(f PgFunctionMetadata) string {
+
+	var ary []string
+	for _, a := range f.CallingArguments {
+		if strings.HasPrefix(a.TypeName, "_") {
+			ary = append(ary, u.ToUpperCamelCase(a.TypeName[1:])+"Array")
+		} else {
+			ary = append(ary, u.ToUpperCamelCase(a.TypeName))
+		}
+	}
+	return "By" + strings.Join(ary, "")
+}
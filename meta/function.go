@@ -3,11 +3,10 @@ package meta
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 
-	_ "github.com/lib/pq"
-
-	u "github.com/gsiems/pg2go/util"
+	"github.com/lib/pq"
 )
 
 // PgFunctionMetadata contains metadata for postgresql functions
@@ -18,58 +17,84 @@ type PgFunctionMetadata struct {
 	ObjType          string `db:"obj_type"`
 	ResultTypes      string `db:"result_types"`
 	ArgumentTypes    string `db:"argument_types"`
-	Privs            string `db:"privs"`
+	ReturnsSet       bool   `db:"returns_set"`
 	Description      string `db:"description"`
+	Privileges       PgPrivileges
+	FuncName         string
 	StructName       string
-	argTypes         string
-	argModes         string
-	argNames         string
+	Overloaded       bool
+	ArgTypes         string   `db:"arg_types"`
+	ArgModes         string   `db:"arg_modes"`
+	ArgNames         []string `db:"arg_names"`                      // the argument names, by argument position, or empty for unnamed arguments
+	ArgDefaults      []string `db:"arg_defaults" json:",omitempty"` // the default expressions, by argument position, or empty for none
 	ResultColumns    []PgColumnMetadata
 	CallingArguments []PgColumnMetadata
+	ResultRowType    string             // the schema.name of the table, view, or composite type returned, if any
+	ResultRowColumns []PgColumnMetadata `json:",omitempty"` // the columns of the row type returned, if any
+	Cursors          []string           // the schema.name of the row type of each refcursor returned, if declared
+	CursorNames      []string           `json:",omitempty"` // the declared name of each refcursor returned, or empty when not named
 }
 
-// GetFunctionMetas returns the metadata for the avaiable functions
-func GetFunctionMetas(db *sql.DB, schema, objName, user string, pgVersion int) (funcs []PgFunctionMetadata, err error) {
+// GetFunctionMetas returns the metadata for the avaiable functions. The
+// argument types are resolved using the OID to type map so the types
+// need to have been read (by GetTypeMetas) first.
+func GetFunctionMetas(cat Catalog, schema, objName, user string, pgVersion int) (funcs []PgFunctionMetadata, err error) {
 
-	funcs, errq := listFunctionMetas(db, schema, objName, user, pgVersion)
+	funcs, errq := cat.ListFunctions(schema, objName, user, pgVersion)
 	if errq != nil {
 		err = fmt.Errorf("Expected function metadata, got error: %q", errq)
 		return
 	}
+	rowTypes := make([]string, len(funcs))
 	for i, f := range funcs {
 		/*
 			fmt.Println("\n-------------------------------------------------------------------")
 			fmt.Printf("GetFunctionMetas: %q.%q\n", f.SchemaName, f.ObjName)
 			fmt.Printf("    ArgumentTypes: %q\n", f.ArgumentTypes)
 			fmt.Printf("    ResultTypes: %q\n", f.ResultTypes)
-			fmt.Printf("    argTypes: %q\n", f.argTypes)
-			fmt.Printf("    argModes: %q\n", f.argModes)
-			fmt.Printf("    argNames: %q\n", f.argNames)
+			fmt.Printf("    ArgTypes: %q\n", f.ArgTypes)
+			fmt.Printf("    ArgModes: %q\n", f.ArgModes)
+			fmt.Printf("    ArgNames: %q\n", f.ArgNames)
 		*/
-		funcs[i].StructName = u.ToUpperCamelCase(f.ObjName)
-
-		if funcs[i].argTypes != "" {
+		if funcs[i].ArgTypes != "" {
 			var fat []PgColumnMetadata
 			var frt []PgColumnMetadata
+			var resultType string
 
-			argtypes := strings.Split(funcs[i].argTypes, ",")
-			argmodes := strings.Split(funcs[i].argModes, ",")
-			argnames := strings.Split(funcs[i].argNames, ",")
+			argtypes := strings.Split(funcs[i].ArgTypes, ",")
+			argmodes := strings.Split(funcs[i].ArgModes, ",")
+			argnames := argNames(funcs[i].ArgNames, len(argtypes))
 
 			for j, argtype := range argtypes {
 
-				c, errq := popTypeMeta(db, argtype)
+				c, errq := argTypeColumn(argtype)
 				if errq != nil {
 					err = fmt.Errorf("Expected function type metadata, got error: %q", errq)
 					return
 				}
 				c.OrdinalPosition = j + 1
 				c.ColumnName = argnames[j]
+				if j < len(f.ArgDefaults) {
+					c.Default = f.ArgDefaults[j]
+				}
 
-				if argmodes[j] == "i" {
+				// The input (i), INOUT (b), and VARIADIC (v) arguments
+				// are passed when calling the function and the OUT (o),
+				// INOUT, and TABLE (t) arguments are returned by it
+				c.ArgMode = "i"
+				if j < len(argmodes) && argmodes[j] != "" {
+					c.ArgMode = argmodes[j]
+				}
+				switch c.ArgMode {
+				case "i", "v":
+					fat = append(fat, c)
+				case "b":
 					fat = append(fat, c)
-				} else {
 					frt = append(frt, c)
+					resultType = argtype
+				default:
+					frt = append(frt, c)
+					resultType = argtype
 				}
 				/*
 					fmt.Println("        -------------------")
@@ -82,16 +107,168 @@ func GetFunctionMetas(db *sql.DB, schema, objName, user string, pgVersion int) (
 				*/
 			}
 
+			setColumnOwner(frt, f.SchemaName, f.ObjName)
+			setColumnOwner(fat, f.SchemaName, f.ObjName)
 			funcs[i].ResultColumns = frt
 			funcs[i].CallingArguments = fat
+
+			// A function with a single result of a row type returns
+			// the columns of that type. The columns are already set
+			// for functions read from a snapshot.
+			if len(frt) == 1 {
+				funcs[i].ResultRowType = rowTypeKey(resultType)
+			}
+			if funcs[i].ResultRowType != "" && len(funcs[i].ResultRowColumns) == 0 {
+				rowTypes[i] = resultType
+			}
+		}
+	}
+
+	err = setRowTypeColumns(cat, funcs, rowTypes, user, pgVersion)
+	if err != nil {
+		return
+	}
+
+	err = setFunctionNames(funcs)
+	if err != nil {
+		return
+	}
+	setFunctionCursors(funcs)
+
+	return
+}
+
+// argNames returns the names for the n arguments of a function. Unnamed
+// arguments are given a name (arg1, arg2, ...) from their position that
+// does not collide with the names of the named arguments.
+func argNames(names []string, n int) []string {
+
+	d := make([]string, n)
+	taken := make(map[string]bool)
+	for j := 0; j < n && j < len(names); j++ {
+		d[j] = names[j]
+		taken[names[j]] = true
+	}
+
+	for j := range d {
+		if d[j] != "" {
+			continue
+		}
+		name := fmt.Sprintf("arg%d", j+1)
+		for k := 2; taken[name]; k++ {
+			name = fmt.Sprintf("arg%d_%d", j+1, k)
 		}
+		taken[name] = true
+		d[j] = name
+	}
+	return d
+}
+
+// ArgIsNamed returns whether a function argument is named in the function
+// definition, as opposed to having been given a name by position
+func ArgIsNamed(f PgFunctionMetadata, col PgColumnMetadata) bool {
+	j := col.OrdinalPosition - 1
+	return j >= 0 && j < len(f.ArgNames) && f.ArgNames[j] != ""
+}
+
+// argTypeColumn returns the column metadata for a function argument type
+// OID
+func argTypeColumn(argType string) (c PgColumnMetadata, err error) {
+
+	oid, err := strconv.Atoi(strings.TrimSpace(argType))
+	if err != nil {
+		err = fmt.Errorf("Invalid type oid %q", argType)
+		return
+	}
+
+	t, ok := tc.oidToType[oid]
+	if !ok {
+		err = fmt.Errorf("Unknown type oid %d", oid)
+		return
 	}
 
+	c.DataType = t.DataType
+	c.TypeName = t.TypeName
+	c.TypeCategory = t.TypeCategory
+	return
+}
+
+// rowTypeKey returns the schema.name key for a table, view, or composite
+// type OID, or an empty string for any other type
+func rowTypeKey(argType string) string {
+
+	oid, err := strconv.Atoi(strings.TrimSpace(argType))
+	if err != nil {
+		return ""
+	}
+
+	t, ok := tc.oidToType[oid]
+	if !ok || t.TypeType != "c" {
+		return ""
+	}
+	return objKey(t.SchemaName, t.TypeName)
+}
+
+// setRowTypeColumns sets the columns of the row types returned by the
+// functions, by the type OIDs in rowTypes. The composite type columns
+// have already been read (by GetTypeMetas) while the table and view
+// columns are read, for all of the functions at once (per schema), as
+// the tables may not be ones that code is generated for. The tables are
+// read by the schema of the type so that they need not be visible in the
+// search path.
+func setRowTypeColumns(cat Catalog, funcs []PgFunctionMetadata, rowTypes []string, user string, pgVersion int) (err error) {
+
+	var schemas []string
+	names := make(map[string][]string)
+	seen := make(map[string]bool)
+	tables := make(map[int]*PgOidTypeMetadata)
+	for i, argType := range rowTypes {
+
+		oid, errq := strconv.Atoi(strings.TrimSpace(argType))
+		if errq != nil {
+			continue
+		}
+
+		t, ok := tc.oidToType[oid]
+		if !ok {
+			continue
+		}
+
+		if ut, ok := tc.userTypes[t.TypeName]; ok && len(ut.Columns) > 0 {
+			funcs[i].ResultRowColumns = append([]PgColumnMetadata(nil), ut.Columns...)
+			continue
+		}
+		tables[i] = t
+		key := objKey(t.SchemaName, t.TypeName)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if len(names[t.SchemaName]) == 0 {
+			schemas = append(schemas, t.SchemaName)
+		}
+		names[t.SchemaName] = append(names[t.SchemaName], t.TypeName)
+	}
+
+	var columns []PgColumnMetadata
+	for _, schema := range schemas {
+		cols, errq := cat.ListTableColumns(schema, strings.Join(names[schema], ","), user, pgVersion)
+		if errq != nil {
+			err = fmt.Errorf("Expected column metadata for the function result types, got error: %q", errq)
+			return
+		}
+		columns = append(columns, cols...)
+	}
+	colMap := groupColumns(columns)
+
+	for i, t := range tables {
+		funcs[i].ResultRowColumns = colMap[objKey(t.SchemaName, t.TypeName)]
+	}
 	return
 }
 
 // listFunctionMetas returns the metadata for the avaiable functions
-func listFunctionMetas(db *sql.DB, schema, objName, user string, pgVersion int) (d []PgFunctionMetadata, err error) {
+func listFunctionMetas(db Queryer, schema, objName, user string, pgVersion int) (d []PgFunctionMetadata, err error) {
 
 	var u struct {
 		SchemaName    sql.NullString
@@ -100,11 +277,13 @@ func listFunctionMetas(db *sql.DB, schema, objName, user string, pgVersion int)
 		ObjType       sql.NullString
 		ResultTypes   sql.NullString
 		ArgumentTypes sql.NullString
-		Privs         sql.NullString
+		ReturnsSet    sql.NullBool
 		Description   sql.NullString
-		argTypes      sql.NullString
-		argModes      sql.NullString
-		argNames      sql.NullString
+		CanExecute    sql.NullBool
+		ArgTypes      sql.NullString
+		ArgModes      sql.NullString
+		ArgNames      []string
+		ArgDefaults   []string
 	}
 
 	var q string
@@ -127,8 +306,8 @@ proc AS (
                 END AS obj_type,
             pg_catalog.pg_get_function_result ( p.oid ) AS result_types,
             pg_catalog.pg_get_function_arguments ( p.oid ) AS argument_types,
+            p.proretset AS returns_set,
             pg_catalog.obj_description(p.oid, 'pg_proc') AS description,
-            p.proacl,
             CASE
                 WHEN p.proallargtypes IS NOT NULL
                     THEN regexp_replace ( p.proallargtypes::text, '[{}]', '', 'g' )
@@ -137,10 +316,16 @@ proc AS (
                 WHEN p.proargmodes IS NOT NULL
                     THEN regexp_replace ( p.proargmodes::text, '[{}]', '', 'g' )
                 END AS all_arg_modes,
+            coalesce ( p.proargnames,
+                array_fill ( ''::text, ARRAY[coalesce ( array_length ( p.proallargtypes, 1 ), p.pronargs )] ) ) AS all_arg_names,
             CASE
-                WHEN p.proargnames IS NOT NULL
-                    THEN regexp_replace ( p.proargnames::text, '[{}]', '', 'g' )
-                END AS all_arg_names,
+                WHEN p.pronargdefaults > 0
+                    THEN ARRAY (
+                        SELECT coalesce ( pg_catalog.pg_get_function_arg_default ( p.oid, a.n ), '' )
+                            FROM generate_series ( 1, coalesce ( array_length ( p.proallargtypes, 1 ), p.pronargs ) ) AS a ( n )
+                            ORDER BY a.n
+                        )
+                END AS arg_defaults,
             CASE
                 WHEN p.proargtypes IS NOT NULL AND p.proargtypes::text <> ''
                     THEN regexp_replace ( p.proargtypes::text, '[ ]+', ',', 'g' )
@@ -156,6 +341,7 @@ proc AS (
             CASE
                 WHEN t.typname IS NOT NULL AND t.typname::text <> ''
                     THEN t.typname::text
+                ELSE ''
                 END AS ret_arg_name
         FROM pg_catalog.pg_proc p
         JOIN pg_catalog.pg_namespace n
@@ -174,39 +360,50 @@ proc AS (
                 OR coalesce ( args.obj_name, '' ) = '' )
 ),
 obj AS (
-    SELECT p.schema_name,
+    SELECT p.oid,
+            p.schema_name,
             p.obj_name,
             p.obj_kind,
             p.obj_type,
             p.result_types,
             p.argument_types,
+            p.returns_set,
             p.description,
-            coalesce ( a.acl::text, '' ) AS acl,
             CASE
+                WHEN coalesce ( p.all_arg_types, '' ) <> '' AND p.all_arg_modes !~ '[obt]' THEN p.all_arg_types || ',' || p.ret_arg_type
                 WHEN coalesce ( p.all_arg_types, '' ) <> '' THEN p.all_arg_types
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.in_arg_types || ',' || p.ret_arg_type
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' THEN p.in_arg_types
                 WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN p.ret_arg_type
                 END AS arg_types,
             CASE
+                WHEN coalesce ( p.all_arg_types, '' ) <> '' AND p.all_arg_modes !~ '[obt]' THEN p.all_arg_modes || ',o'
                 WHEN coalesce ( p.all_arg_types, '' ) <> '' THEN p.all_arg_modes
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.in_arg_modes || ',o'
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' THEN p.in_arg_modes
                 WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN 'o'
                 END AS arg_modes,
             CASE
+                WHEN coalesce ( p.all_arg_types, '' ) <> '' AND p.all_arg_modes !~ '[obt]' THEN p.all_arg_names || p.ret_arg_name
                 WHEN coalesce ( p.all_arg_types, '' ) <> '' THEN p.all_arg_names
-                WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.all_arg_names || ',' || p.ret_arg_name
+                WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.all_arg_names || p.ret_arg_name
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' THEN p.all_arg_names
-                WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN p.ret_arg_name
-                END AS arg_names
+                WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN ARRAY[p.ret_arg_name]
+                END AS arg_names,
+            p.arg_defaults
         FROM proc p
-        LEFT JOIN (
-            SELECT oid,
-                    unnest ( proacl ) AS acl
-                FROM proc
-            ) a
-            ON ( a.oid = p.oid )
+),
+privs AS (
+    SELECT obj.oid,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_function_privilege ( usr.username::name, obj.oid, 'EXECUTE' )
+                END AS can_execute
+        FROM obj
+        CROSS JOIN (
+            SELECT DISTINCT username
+                FROM args
+            ) usr
 )
 SELECT DISTINCT obj.schema_name,
         obj.obj_name,
@@ -214,18 +411,17 @@ SELECT DISTINCT obj.schema_name,
         obj.obj_type,
         coalesce ( obj.result_types, '' ) AS result_types,
         coalesce ( obj.argument_types, '' ) AS argument_types,
-        CASE
-            WHEN args.username = '' THEN ''
-            ELSE coalesce ( regexp_replace ( regexp_replace ( obj.acl, '^[^=]+=', '' ), '[/].+', '' ), '' )
-            END AS privs,
+        obj.returns_set,
         coalesce ( obj.description, '' ) AS description,
+        privs.can_execute,
         arg_types,
         arg_modes,
-        arg_names
+        arg_names,
+        arg_defaults
     FROM obj
-    CROSS JOIN args
-    WHERE ( obj.acl LIKE args.username || '=%'
-            OR args.username = '' )
+    JOIN privs
+        ON ( privs.oid = obj.oid )
+    WHERE privs.can_execute
     ORDER BY 1, 2, 4
 `
 	default:
@@ -240,8 +436,8 @@ proc AS (
             p.proname::text AS obj_name,
             pg_catalog.pg_get_function_result ( p.oid ) AS result_types,
             pg_catalog.pg_get_function_arguments ( p.oid ) AS argument_types,
+            p.proretset AS returns_set,
             pg_catalog.obj_description(p.oid, 'pg_proc') AS description,
-            p.proacl,
             CASE
                 WHEN p.proallargtypes IS NOT NULL
                     THEN regexp_replace ( p.proallargtypes::text, '[{}]', '', 'g' )
@@ -250,10 +446,16 @@ proc AS (
                 WHEN p.proargmodes IS NOT NULL
                     THEN regexp_replace ( p.proargmodes::text, '[{}]', '', 'g' )
                 END AS all_arg_modes,
+            coalesce ( p.proargnames,
+                array_fill ( ''::text, ARRAY[coalesce ( array_length ( p.proallargtypes, 1 ), p.pronargs )] ) ) AS all_arg_names,
             CASE
-                WHEN p.proargnames IS NOT NULL
-                    THEN regexp_replace ( p.proargnames::text, '[{}]', '', 'g' )
-                END AS all_arg_names,
+                WHEN p.pronargdefaults > 0
+                    THEN ARRAY (
+                        SELECT coalesce ( pg_catalog.pg_get_function_arg_default ( p.oid, a.n ), '' )
+                            FROM generate_series ( 1, coalesce ( array_length ( p.proallargtypes, 1 ), p.pronargs ) ) AS a ( n )
+                            ORDER BY a.n
+                        )
+                END AS arg_defaults,
             CASE
                 WHEN p.proargtypes IS NOT NULL AND p.proargtypes::text <> ''
                     THEN regexp_replace ( p.proargtypes::text, '[ ]+', ',', 'g' )
@@ -269,6 +471,7 @@ proc AS (
             CASE
                 WHEN t.typname IS NOT NULL AND t.typname::text <> ''
                     THEN t.typname::text
+                ELSE ''
                 END AS ret_arg_name
         FROM pg_catalog.pg_proc p
         JOIN pg_catalog.pg_namespace n
@@ -288,37 +491,48 @@ proc AS (
                 OR coalesce ( args.obj_name, '' ) = '' )
 ),
 obj AS (
-    SELECT p.schema_name,
+    SELECT p.oid,
+            p.schema_name,
             p.obj_name,
             p.result_types,
             p.argument_types,
+            p.returns_set,
             p.description,
-            coalesce ( a.acl::text, '' ) AS acl,
             CASE
+                WHEN coalesce ( p.all_arg_types, '' ) <> '' AND p.all_arg_modes !~ '[obt]' THEN p.all_arg_types || ',' || p.ret_arg_type
                 WHEN coalesce ( p.all_arg_types, '' ) <> '' THEN p.all_arg_types
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.in_arg_types || ',' || p.ret_arg_type
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' THEN p.in_arg_types
                 WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN p.ret_arg_type
                 END AS arg_types,
             CASE
+                WHEN coalesce ( p.all_arg_types, '' ) <> '' AND p.all_arg_modes !~ '[obt]' THEN p.all_arg_modes || ',o'
                 WHEN coalesce ( p.all_arg_types, '' ) <> '' THEN p.all_arg_modes
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.in_arg_modes || ',o'
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' THEN p.in_arg_modes
                 WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN 'o'
                 END AS arg_modes,
             CASE
+                WHEN coalesce ( p.all_arg_types, '' ) <> '' AND p.all_arg_modes !~ '[obt]' THEN p.all_arg_names || p.ret_arg_name
                 WHEN coalesce ( p.all_arg_types, '' ) <> '' THEN p.all_arg_names
-                WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.all_arg_names || ',' || p.ret_arg_name
+                WHEN coalesce ( p.in_arg_types, '' ) <> '' AND coalesce ( p.ret_arg_type, '' ) <> '' THEN p.all_arg_names || p.ret_arg_name
                 WHEN coalesce ( p.in_arg_types, '' ) <> '' THEN p.all_arg_names
-                WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN p.ret_arg_name
-                END AS arg_names
+                WHEN coalesce ( p.ret_arg_type, '' ) <> '' THEN ARRAY[p.ret_arg_name]
+                END AS arg_names,
+            p.arg_defaults
         FROM proc p
-        LEFT JOIN (
-            SELECT oid,
-                    unnest ( proacl ) AS acl
-                FROM proc
-            ) a
-            ON ( a.oid = p.oid )
+),
+privs AS (
+    SELECT obj.oid,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_function_privilege ( usr.username::name, obj.oid, 'EXECUTE' )
+                END AS can_execute
+        FROM obj
+        CROSS JOIN (
+            SELECT DISTINCT username
+                FROM args
+            ) usr
 )
 SELECT DISTINCT obj.schema_name,
         obj.obj_name,
@@ -326,18 +540,17 @@ SELECT DISTINCT obj.schema_name,
         'function' AS obj_type,
         coalesce ( obj.result_types, '' ) AS result_types,
         coalesce ( obj.argument_types, '' ) AS argument_types,
-        CASE
-            WHEN args.username = '' THEN ''
-            ELSE coalesce ( regexp_replace ( regexp_replace ( obj.acl::text, '^[^=]+=', '' ), '[/].+', '' ), '' )
-            END AS privs,
+        obj.returns_set,
         coalesce ( obj.description, '' ) AS description,
+        privs.can_execute,
         arg_types,
         arg_modes,
-        arg_names
+        arg_names,
+        arg_defaults
     FROM obj
-    CROSS JOIN args
-    WHERE ( obj.acl LIKE args.username || '=%'
-            OR args.username = '' )
+    JOIN privs
+        ON ( privs.oid = obj.oid )
+    WHERE privs.can_execute
     ORDER BY obj.schema_name,
         obj.obj_name,
         obj.argument_types
@@ -358,11 +571,13 @@ SELECT DISTINCT obj.schema_name,
 			&u.ObjType,
 			&u.ResultTypes,
 			&u.ArgumentTypes,
-			&u.Privs,
+			&u.ReturnsSet,
 			&u.Description,
-			&u.argTypes,
-			&u.argModes,
-			&u.argNames,
+			&u.CanExecute,
+			&u.ArgTypes,
+			&u.ArgModes,
+			pq.Array(&u.ArgNames),
+			pq.Array(&u.ArgDefaults),
 		)
 		if err != nil {
 			return
@@ -375,42 +590,15 @@ SELECT DISTINCT obj.schema_name,
 			ObjType:       u.ObjType.String,
 			ResultTypes:   u.ResultTypes.String,
 			ArgumentTypes: u.ArgumentTypes.String,
-			Privs:         u.Privs.String,
+			ReturnsSet:    u.ReturnsSet.Bool,
 			Description:   u.Description.String,
-			argTypes:      u.argTypes.String,
-			argModes:      u.argModes.String,
-			argNames:      u.argNames.String,
+			Privileges:    PgPrivileges{Execute: u.CanExecute.Bool},
+			ArgTypes:      u.ArgTypes.String,
+			ArgModes:      u.ArgModes.String,
+			ArgNames:      u.ArgNames,
+			ArgDefaults:   u.ArgDefaults,
 		})
 	}
 
 	return
 }
-
-// popTypeMeta returns the metadata for the specified type
-func popTypeMeta(db *sql.DB, arg_type string) (u PgColumnMetadata, err error) {
-
-	q := `
-SELECT pg_catalog.format_type ( oid, null ) AS data_type,
-        typname AS type_name,
-        typcategory AS type_category
-    FROM pg_catalog.pg_type
-    WHERE oid::text = $1
-`
-
-	rows, err := db.Query(q, arg_type)
-	if err != nil {
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-
-		err = rows.Scan(&u.DataType,
-			&u.TypeName,
-			&u.TypeCategory,
-		)
-
-	}
-
-	return
-}
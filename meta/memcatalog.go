@@ -0,0 +1,140 @@
+package meta
+
+import (
+	"regexp"
+)
+
+var reObjNameSep = regexp.MustCompile(`, *`)
+
+// MemCatalog is an in-memory Catalog. It is loaded from a metadata
+// snapshot, or from test fixtures in the same format. As the privileges
+// in the snapshot are those of the user that it was dumped for, the
+// objects are not filtered by user.
+type MemCatalog struct {
+	s Snapshot
+}
+
+// NewMemCatalog returns the in-memory Catalog for a metadata snapshot
+func NewMemCatalog(s Snapshot) *MemCatalog {
+	return &MemCatalog{s: s}
+}
+
+// LoadMemCatalog returns the in-memory Catalog for a JSON metadata
+// snapshot, or fixture, file
+func LoadMemCatalog(fileName string) (c *MemCatalog, err error) {
+
+	s, err := ReadSnapshot(fileName)
+	if err != nil {
+		return
+	}
+
+	c = NewMemCatalog(s)
+	return
+}
+
+// inSchema checks if a schema name matches the schema filter
+func inSchema(schemaName, schema string) bool {
+	return schema == "" || schemaName == schema
+}
+
+// inObjects checks if an object name matches the comma-separated list
+// of object names
+func inObjects(objName, objNames string) bool {
+	if objNames == "" {
+		return true
+	}
+	for _, s := range reObjNameSep.Split(objNames, -1) {
+		if s == objName {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *MemCatalog) ListEnums(schema string) (d []PgEnumMetadata, err error) {
+	for _, e := range c.s.Enums {
+		if inSchema(e.SchemaName, schema) {
+			d = append(d, e)
+		}
+	}
+	return
+}
+
+func (c *MemCatalog) ListDomains(schema string) (d []PgDomainMetadata, err error) {
+	for _, v := range c.s.Domains {
+		if inSchema(v.SchemaName, schema) {
+			d = append(d, v)
+		}
+	}
+	return
+}
+
+func (c *MemCatalog) ListOidTypes() (d []PgOidTypeMetadata, err error) {
+	d = append(d, c.s.OidTypes...)
+	return
+}
+
+func (c *MemCatalog) ListTypes(schema, objName string) (d []PgUsertypeMetadata, err error) {
+	for _, t := range c.s.Types {
+		if inSchema(t.SchemaName, schema) && inObjects(t.ObjName, objName) {
+			t.Columns = nil
+			d = append(d, t)
+		}
+	}
+	return
+}
+
+func (c *MemCatalog) ListTypeColumns(schema, objName string) (d []PgColumnMetadata, err error) {
+	for _, t := range c.s.Types {
+		if inSchema(t.SchemaName, schema) && inObjects(t.ObjName, objName) {
+			cols := append([]PgColumnMetadata(nil), t.Columns...)
+			setColumnOwner(cols, t.SchemaName, t.TypeName)
+			d = append(d, cols...)
+		}
+	}
+	return
+}
+
+func (c *MemCatalog) ListTables(schema, objName, user string) (d []PgTableMetadata, err error) {
+	for _, t := range c.s.Tables {
+		if inSchema(t.SchemaName, schema) && inObjects(t.ObjName, objName) {
+			t.Columns = nil
+			t.ForeignKeys = nil
+			d = append(d, t)
+		}
+	}
+	return
+}
+
+func (c *MemCatalog) ListTableColumns(schema, objName, user string, pgVersion int) (d []PgColumnMetadata, err error) {
+	for _, t := range c.s.Tables {
+		if inSchema(t.SchemaName, schema) && inObjects(t.ObjName, objName) {
+			cols := append([]PgColumnMetadata(nil), t.Columns...)
+			setColumnOwner(cols, t.SchemaName, t.ObjName)
+			d = append(d, cols...)
+		}
+	}
+	return
+}
+
+func (c *MemCatalog) ListForeignKeys(schema, objName string) (d []PgForeignKeyMetadata, err error) {
+	for _, t := range c.s.Tables {
+		if inSchema(t.SchemaName, schema) && inObjects(t.ObjName, objName) {
+			for _, fk := range t.ForeignKeys {
+				fk.SchemaName = t.SchemaName
+				fk.ObjName = t.ObjName
+				d = append(d, fk)
+			}
+		}
+	}
+	return
+}
+
+func (c *MemCatalog) ListFunctions(schema, objName, user string, pgVersion int) (d []PgFunctionMetadata, err error) {
+	for _, f := range c.s.Functions {
+		if inSchema(f.SchemaName, schema) && inObjects(f.ObjName, objName) {
+			d = append(d, f)
+		}
+	}
+	return
+}
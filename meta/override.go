@@ -0,0 +1,81 @@
+package meta
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TypeOverride is a Go type to use in place of the default translation
+// of a Postgresql type or column, along with the imports that the Go type
+// needs
+type TypeOverride struct {
+	GoType     string   `json:"go_type"`
+	NullGoType string   `json:"null_go_type"` // for nullable columns, defaults to GoType
+	Imports    []string `json:"imports"`
+}
+
+// SetTypeOverrides sets the overrides for translating the Postgresql
+// types (keyed by type name) and columns (keyed by schema.table.column)
+func SetTypeOverrides(types, columns map[string]TypeOverride) error {
+
+	for k, v := range types {
+		if v.GoType == "" {
+			return fmt.Errorf("No Go type specified for the %q type override", k)
+		}
+	}
+	for k, v := range columns {
+		if v.GoType == "" {
+			return fmt.Errorf("No Go type specified for the %q column override", k)
+		}
+	}
+
+	tc.typeOverrides = types
+	tc.columnOverrides = columns
+	return nil
+}
+
+// columnKey returns the key for looking up the override for a column
+func columnKey(col PgColumnMetadata) string {
+	return fmt.Sprintf("%s.%s.%s", col.SchemaName, col.ObjName, col.ColumnName)
+}
+
+// columnOverride returns the override, if any, for a column. Column
+// overrides take precedence over type overrides.
+func columnOverride(col PgColumnMetadata) (o TypeOverride, ok bool) {
+
+	o, ok = tc.columnOverrides[columnKey(col)]
+	if ok {
+		return
+	}
+	o, ok = tc.typeOverrides[col.TypeName]
+	return
+}
+
+// TypeImports returns the imports needed for a Postgresql type
+func TypeImports(typeName string) []string {
+	return tc.typeOverrides[typeName].Imports
+}
+
+// ColumnImports returns the sorted list of imports needed for the
+// overridden column types
+func ColumnImports(cols []PgColumnMetadata) (d []string) {
+
+	seen := make(map[string]int)
+
+	for _, col := range cols {
+		o, ok := columnOverride(col)
+		if !ok {
+			continue
+		}
+		for _, s := range o.Imports {
+			_, ok = seen[s]
+			if !ok {
+				seen[s] = 1
+				d = append(d, s)
+			}
+		}
+	}
+
+	sort.Strings(d)
+	return
+}
@@ -0,0 +1,76 @@
+package meta
+
+import "strings"
+
+// polymorphicTypes maps the Postgresql polymorphic pseudo-types to the Go
+// type parameters (or slices of them) that they are generated as. The
+// types of a family resolve to the same actual type when the function is
+// called so they share a type parameter: T for the anyelement family and
+// U for the anycompatible family. As Go can not express "a range of T"
+// the range and multirange types get type parameters of their own.
+var polymorphicTypes = map[string]string{
+	"anyelement":              "T",
+	"anynonarray":             "T",
+	"anyenum":                 "T",
+	"anyarray":                "[]T",
+	"anyrange":                "R",
+	"anymultirange":           "M",
+	"anycompatible":           "U",
+	"anycompatiblenonarray":   "U",
+	"anycompatiblearray":      "[]U",
+	"anycompatiblerange":      "UR",
+	"anycompatiblemultirange": "UM",
+}
+
+// typeParamOrder is the order of the type parameters of the generic
+// functions and structs
+var typeParamOrder = []string{"T", "U", "R", "M", "UR", "UM"}
+
+// IsPolymorphic returns true if the Postgresql type name is a polymorphic
+// pseudo-type
+func IsPolymorphic(typeName string) bool {
+	_, ok := polymorphicTypes[typeName]
+	return ok
+}
+
+// IsPolymorphicArray returns true if the Postgresql type name is one of
+// the polymorphic array pseudo-types
+func IsPolymorphicArray(typeName string) bool {
+	return strings.HasPrefix(polymorphicTypes[typeName], "[]")
+}
+
+// TypeParams returns the Go type parameters, in order, that are used by
+// the polymorphic columns
+func TypeParams(cols []PgColumnMetadata) (d []string) {
+
+	used := make(map[string]bool)
+	for _, col := range cols {
+		if n, ok := polymorphicTypes[col.TypeName]; ok {
+			used[strings.TrimPrefix(n, "[]")] = true
+		}
+	}
+
+	for _, n := range typeParamOrder {
+		if used[n] {
+			d = append(d, n)
+		}
+	}
+	return
+}
+
+// PgtypeCastNames returns the Postgresql type names, keyed by the pgtype
+// type, for casting the arguments of polymorphic functions
+func PgtypeCastNames() map[string]string {
+	d := make(map[string]string)
+	for k, v := range tc.pgTypes {
+		switch k {
+		case "record", "unknown":
+			continue
+		case "char":
+			// the unquoted char is character(1)
+			k = `"char"`
+		}
+		d[v] = k
+	}
+	return d
+}
@@ -0,0 +1,19 @@
+package meta
+
+// PgPrivileges contains the privileges that the application user has on
+// a database object. The privileges are resolved by the database (using
+// the has_*_privilege functions) so they include those granted to PUBLIC,
+// those granted to roles that the user is a member of, and those that the
+// user has as the owner. When no application user is specified then all
+// privileges are assumed. For columns, only the select, insert, update,
+// and references privileges apply.
+type PgPrivileges struct {
+	Select     bool `db:"can_select" json:",omitempty"`
+	Insert     bool `db:"can_insert" json:",omitempty"`
+	Update     bool `db:"can_update" json:",omitempty"`
+	Delete     bool `db:"can_delete" json:",omitempty"`
+	Truncate   bool `db:"can_truncate" json:",omitempty"`
+	References bool `db:"can_references" json:",omitempty"`
+	Trigger    bool `db:"can_trigger" json:",omitempty"`
+	Execute    bool `db:"can_execute" json:",omitempty"`
+}
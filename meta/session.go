@@ -0,0 +1,97 @@
+package meta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BeginSession starts the REPEATABLE READ READ ONLY transaction that the
+// metadata is read in so that all of the metadata is from the same
+// snapshot of the database, and returns the position of that snapshot.
+// The position is read by the first statement of the transaction, as
+// that is the statement that establishes the snapshot. The statement
+// and lock timeouts (when not zero) are set for the transaction only.
+func BeginSession(db *sql.DB, pgVersion int, statementTimeout, lockTimeout time.Duration) (tx *sql.Tx, pos string, err error) {
+
+	tx, err = db.BeginTx(context.Background(), &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return
+	}
+
+	pos, err = snapshotPosition(tx, pgVersion)
+	if err != nil {
+		tx.Rollback()
+		err = fmt.Errorf("Unable to read the snapshot position: %s", err)
+		return
+	}
+
+	settings := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"statement_timeout", statementTimeout},
+		{"lock_timeout", lockTimeout},
+	}
+
+	for _, s := range settings {
+		if s.value <= 0 {
+			continue
+		}
+
+		var rows *sql.Rows
+		rows, err = tx.Query("SELECT pg_catalog.set_config ( $1, $2, true )", s.name, fmt.Sprintf("%dms", s.value.Milliseconds()))
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("Unable to set %s: %s", s.name, err)
+			return
+		}
+		rows.Close()
+	}
+
+	return
+}
+
+// snapshotPosition returns the position of the snapshot that the
+// metadata is read from: the transaction snapshot on a primary, or the
+// replayed WAL location on a replica. On a replica the replay continues
+// during the transaction so this needs to be the first query in the
+// transaction for the location to match the snapshot.
+func snapshotPosition(db Queryer, pgVersion int) (pos string, err error) {
+
+	var q string
+
+	switch {
+	case pgVersion >= 100000:
+		q = `
+SELECT CASE
+            WHEN pg_catalog.pg_is_in_recovery ()
+                THEN 'replay LSN ' || coalesce ( pg_catalog.pg_last_wal_replay_lsn ()::text, 'unknown' )
+            ELSE 'txid snapshot ' || pg_catalog.txid_current_snapshot ()::text
+            END AS position
+`
+	default:
+		q = `
+SELECT CASE
+            WHEN pg_catalog.pg_is_in_recovery ()
+                THEN 'replay LSN ' || coalesce ( pg_catalog.pg_last_xlog_replay_location ()::text, 'unknown' )
+            ELSE 'txid snapshot ' || pg_catalog.txid_current_snapshot ()::text
+            END AS position
+`
+	}
+
+	rows, err := db.Query(q)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&pos)
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SnapshotVersion is the version of the metadata snapshot format. It is
+// incremented whenever the format changes in a way that older snapshots
+// can not be read, or would be read with missing metadata that changes
+// the generated code (as when a snapshot without the column privileges
+// would generate tables with no columns).
+const SnapshotVersion = 1
+
+// Snapshot contains the metadata needed for generating code without a
+// database connection
+type Snapshot struct {
+	Version   int
+	DbHost    string
+	DbName    string
+	Position  string // the database snapshot that the metadata was read from
+	Schema    string
+	Objects   string
+	AppUser   string
+	PgVersion int
+	Enums     []PgEnumMetadata
+	Domains   []PgDomainMetadata
+	Types     []PgUsertypeMetadata
+	Tables    []PgTableMetadata
+	Functions []PgFunctionMetadata
+	OidTypes  []PgOidTypeMetadata
+}
+
+// GetSnapshot returns the metadata snapshot for the catalog. The enums,
+// domains, and types are read first as they are needed for translating
+// the other objects.
+func GetSnapshot(cat Catalog, schema, objName, user string, pgVersion int) (s Snapshot, err error) {
+
+	s.Version = SnapshotVersion
+	s.Schema = schema
+	s.Objects = objName
+	s.AppUser = user
+	s.PgVersion = pgVersion
+
+	resetUserTypes()
+
+	s.Enums, err = GetEnumMetas(cat, schema, objName, user, pgVersion)
+	if err != nil {
+		return
+	}
+	s.Domains, err = GetDomainMetas(cat, schema, objName, user, pgVersion)
+	if err != nil {
+		return
+	}
+	s.Types, err = GetTypeMetas(cat, schema, objName, user, pgVersion)
+	if err != nil {
+		return
+	}
+	s.Tables, err = GetTableMetas(cat, schema, objName, user, pgVersion)
+	if err != nil {
+		return
+	}
+	s.Functions, err = GetFunctionMetas(cat, schema, objName, user, pgVersion)
+	if err != nil {
+		return
+	}
+	s.Types = addReferencedTypes(s.Types, s.Tables, s.Functions)
+
+	cols := referencedColumns(s.Types, s.Tables, s.Functions)
+	s.Domains = addReferencedDomains(s.Domains, cols)
+	s.Enums = addReferencedEnums(s.Enums, s.Domains, cols)
+
+	err = checkFunctionNames(s)
+	if err != nil {
+		return
+	}
+
+	for _, t := range tc.oidToType {
+		s.OidTypes = append(s.OidTypes, *t)
+	}
+	sort.Slice(s.OidTypes, func(i, j int) bool { return s.OidTypes[i].Oid < s.OidTypes[j].Oid })
+
+	return
+}
+
+// WriteSnapshot writes the metadata snapshot as JSON
+func WriteSnapshot(fileName string, s Snapshot) (err error) {
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+
+	if fileName == "" || fileName == "-" {
+		_, err = os.Stdout.Write(b)
+		return
+	}
+	return os.WriteFile(fileName, b, 0644)
+}
+
+// ReadSnapshot reads a metadata snapshot
+func ReadSnapshot(fileName string) (s Snapshot, err error) {
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+
+	if s.Version != SnapshotVersion {
+		err = fmt.Errorf("Unsupported snapshot version %d, expected version %d", s.Version, SnapshotVersion)
+		return
+	}
+
+	return
+}
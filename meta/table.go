@@ -1,7 +1,6 @@
 package meta
 
 import (
-	"database/sql"
 	"fmt"
 
 	_ "github.com/lib/pq"
@@ -15,35 +14,55 @@ type PgTableMetadata struct {
 	ObjName     string `db:"obj_name"`
 	ObjKind     string `db:"obj_kind"`
 	ObjType     string `db:"obj_type"`
-	Privs       string `db:"privs"`
 	Description string `db:"description"`
+	Privileges  PgPrivileges
 	StructName  string
 	Columns     []PgColumnMetadata
+	ForeignKeys []PgForeignKeyMetadata
 }
 
 // GetTableMetas returns the metadata for the avaiable tables/views
-func GetTableMetas(db *sql.DB, schema, objName, user string, pgVersion int) (tables []PgTableMetadata, err error) {
+func GetTableMetas(cat Catalog, schema, objName, user string, pgVersion int) (tables []PgTableMetadata, err error) {
 
-	tables, errq := listTableMetas(db, schema, objName, user)
+	tables, errq := cat.ListTables(schema, objName, user)
 	if errq != nil {
 		err = fmt.Errorf("Expected table metadata, got error: %q", errq)
 		return
 	}
+
+	// The columns and foreign keys are read for all of the tables at
+	// once rather than per table
+	columns, errq := cat.ListTableColumns(schema, objName, user, pgVersion)
+	if errq != nil {
+		err = fmt.Errorf("Expected column metadata for tables, got error: %q", errq)
+		return
+	}
+	colMap := groupColumns(columns)
+
+	fks, errq := cat.ListForeignKeys(schema, objName)
+	if errq != nil {
+		err = fmt.Errorf("Expected foreign key metadata for tables, got error: %q", errq)
+		return
+	}
+	fkMap := groupForeignKeys(fks)
+
 	for i, f := range tables {
 		tables[i].StructName = u.ToUpperCamelCase(f.ObjName)
+		tables[i].Columns = colMap[objKey(f.SchemaName, f.ObjName)]
 
-		columns, errq := listTableColumnMetas(db, f.SchemaName, f.ObjName)
-		if errq != nil {
-			err = fmt.Errorf("Expected column metadata for tables, got error: %q", errq)
-			return
+		switch f.ObjKind {
+		case "r", "p":
+			tables[i].ForeignKeys = fkMap[objKey(f.SchemaName, f.ObjName)]
 		}
-		tables[i].Columns = columns
 	}
 	return
 }
 
-// listTableMetas returns the list of avaiable tables/views
-func listTableMetas(db *sql.DB, schema, objName, user string) (d []PgTableMetadata, err error) {
+// listTableMetas returns the list of avaiable tables/views. When a user
+// is specified, only those that the user has privileges on, or on any of
+// the columns of, are returned. The select, insert, update, and
+// references privileges include those granted on any of the columns.
+func listTableMetas(db Queryer, schema, objName, user string) (d []PgTableMetadata, err error) {
 
 	var u PgTableMetadata
 
@@ -54,7 +73,8 @@ WITH args AS (
             $3 AS username
 ),
 obj AS (
-    SELECT n.nspname::text AS schema_name,
+    SELECT c.oid,
+            n.nspname::text AS schema_name,
             c.relname::text AS obj_name,
             c.relkind::text AS obj_kind,
             CASE c.relkind
@@ -67,17 +87,8 @@ obj AS (
                 WHEN 'f' THEN 'foreign table'
                 WHEN 'p' THEN 'table'
                 END AS obj_type,
-            pg_catalog.obj_description(c.oid, 'pg_class') AS description,
-            coalesce ( a.acl::text, '' ) AS acl
+            pg_catalog.obj_description(c.oid, 'pg_class') AS description
         FROM pg_catalog.pg_class c
-        -- Add left join to acl to deal with relationships that have no acl not being in the result set
-        LEFT JOIN (
-            SELECT oid,
-                    unnest ( relacl ) AS acl
-                FROM pg_catalog.pg_class
-                WHERE relkind IN ( 'r', 'v', 'm', 'S', 's', 'f', 'p', '' )
-            ) a
-            ON ( a.oid = c.oid )
         JOIN pg_catalog.pg_namespace n
             ON n.oid = c.relnamespace
         CROSS JOIN args
@@ -88,24 +99,67 @@ obj AS (
             AND n.nspname !~ '^pg_toast'
             AND ( n.nspname = args.schema_name
                 OR args.schema_name = '' )
+            AND ( c.relname = args.obj_name
+                OR coalesce ( args.obj_name, '' ) = '' )
+),
+privs AS (
+    SELECT obj.oid,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_any_column_privilege ( usr.username::name, obj.oid, 'SELECT' )
+                END AS can_select,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_any_column_privilege ( usr.username::name, obj.oid, 'INSERT' )
+                END AS can_insert,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_any_column_privilege ( usr.username::name, obj.oid, 'UPDATE' )
+                END AS can_update,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_table_privilege ( usr.username::name, obj.oid, 'DELETE' )
+                END AS can_delete,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_table_privilege ( usr.username::name, obj.oid, 'TRUNCATE' )
+                END AS can_truncate,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_any_column_privilege ( usr.username::name, obj.oid, 'REFERENCES' )
+                END AS can_references,
+            CASE
+                WHEN usr.username = '' THEN true
+                ELSE pg_catalog.has_table_privilege ( usr.username::name, obj.oid, 'TRIGGER' )
+                END AS can_trigger
+        FROM obj
+        CROSS JOIN (
+            SELECT DISTINCT username
+                FROM args
+            ) usr
 )
--- when no user is specified then we get potential duplicates based on
--- how many users have privs to the object
 SELECT DISTINCT obj.schema_name,
         obj.obj_name,
         obj.obj_kind,
         obj.obj_type,
-        CASE
-            WHEN args.username = '' THEN ''
-            ELSE regexp_replace ( regexp_replace ( obj.acl, '^[^=]+=', '' ), '[/].+', '' )
-            END AS privs,
-        coalesce ( obj.description, '' ) AS description
+        coalesce ( obj.description, '' ) AS description,
+        privs.can_select,
+        privs.can_insert,
+        privs.can_update,
+        privs.can_delete,
+        privs.can_truncate,
+        privs.can_references,
+        privs.can_trigger
     FROM obj
-    CROSS JOIN args
-    WHERE ( obj.obj_name = args.obj_name
-            OR coalesce ( args.obj_name, '' ) = '' )
-        AND ( obj.acl LIKE args.username || '=%'
-             OR args.username = '' )
+    JOIN privs
+        ON ( privs.oid = obj.oid )
+    WHERE privs.can_select
+        OR privs.can_insert
+        OR privs.can_update
+        OR privs.can_delete
+        OR privs.can_truncate
+        OR privs.can_references
+        OR privs.can_trigger
     ORDER BY obj.schema_name,
         obj.obj_name,
         obj.obj_type
@@ -123,8 +177,14 @@ SELECT DISTINCT obj.schema_name,
 			&u.ObjName,
 			&u.ObjKind,
 			&u.ObjType,
-			&u.Privs,
 			&u.Description,
+			&u.Privileges.Select,
+			&u.Privileges.Insert,
+			&u.Privileges.Update,
+			&u.Privileges.Delete,
+			&u.Privileges.Truncate,
+			&u.Privileges.References,
+			&u.Privileges.Trigger,
 		)
 		if err != nil {
 			return
@@ -136,17 +196,35 @@ SELECT DISTINCT obj.schema_name,
 	return
 }
 
-// listTableColumnMetas returns the metadata for the avaiable table/view columns
-func listTableColumnMetas(db *sql.DB, schema, objName string) (d []PgColumnMetadata, err error) {
+// listTableColumnMetas returns the metadata for the columns of the
+// avaiable tables/views, including the column privileges of the user
+// and whether the columns are identity, generated, or defaulted columns.
+// The tables need only be visible in the search path when no schema is
+// given.
+func listTableColumnMetas(db Queryer, schema, objName, user string, pgVersion int) (d []PgColumnMetadata, err error) {
 
 	var u PgColumnMetadata
 
-	q := `WITH args AS (
+	// Identity columns are from Postgresql 10 and generated columns are
+	// from Postgresql 12
+	identity := "''"
+	if pgVersion >= 100000 {
+		identity = "a.attidentity::text"
+	}
+	generated := "''"
+	if pgVersion >= 120000 {
+		generated = "a.attgenerated::text"
+	}
+
+	q := fmt.Sprintf(`WITH args AS (
     SELECT $1 AS schema_name,
-            $2 AS obj_name
+            regexp_split_to_table ( $2, ', *' ) AS obj_name,
+            $3 AS username
 ),
 cols AS (
-    SELECT n.nspname::text AS schema_name,
+    SELECT DISTINCT c.oid,
+            a.attnum,
+            n.nspname::text AS schema_name,
             c.relname::text AS obj_name,
             a.attname::text AS column_name,
             pg_catalog.format_type ( a.atttypid, a.atttypmod ) AS data_type,
@@ -154,7 +232,11 @@ cols AS (
             t.typcategory AS type_category,
             a.attnotnull AS is_required,
             a.attnum AS ordinal_position,
-            pg_catalog.col_description ( a.attrelid, a.attnum ) AS description
+            pg_catalog.col_description ( a.attrelid, a.attnum ) AS description,
+            %s AS identity,
+            %s AS generated,
+            a.atthasdef AS has_default,
+            args.username
         FROM pg_catalog.pg_attribute a
         JOIN pg_catalog.pg_class c
             ON ( c.oid = a.attrelid )
@@ -165,8 +247,15 @@ cols AS (
         CROSS JOIN args
         WHERE a.attnum > 0
             AND NOT a.attisdropped
-            AND n.nspname = args.schema_name
-            AND c.relname = args.obj_name
+            AND c.relkind IN ( 'r', 'v', 'm', 'S', 's', 'f', 'p', '' )
+            AND n.nspname <> 'pg_catalog'
+            AND n.nspname <> 'information_schema'
+            AND n.nspname !~ '^pg_toast'
+            AND ( n.nspname = args.schema_name
+                OR ( args.schema_name = ''
+                    AND pg_catalog.pg_table_is_visible ( c.oid ) ) )
+            AND ( c.relname = args.obj_name
+                OR coalesce ( args.obj_name, '' ) = '' )
 ),
 pk AS (
     SELECT nr.nspname AS schema_name,
@@ -181,7 +270,9 @@ pk AS (
             AND c.contype = 'p'
             AND c.contype <> 'f'
 )
-SELECT cols.column_name,
+SELECT cols.schema_name,
+        cols.obj_name,
+        cols.column_name,
         cols.data_type,
         cols.type_name,
         cols.type_category,
@@ -191,16 +282,37 @@ SELECT cols.column_name,
             WHEN pk.column_name IS NOT NULL THEN true
             ELSE false
             END AS is_pk,
-        coalesce ( cols.description, '' ) AS description
+        coalesce ( cols.description, '' ) AS description,
+        cols.identity,
+        cols.generated,
+        cols.has_default,
+        CASE
+            WHEN cols.username = '' THEN true
+            ELSE pg_catalog.has_column_privilege ( cols.username::name, cols.oid, cols.attnum, 'SELECT' )
+            END AS can_select,
+        CASE
+            WHEN cols.username = '' THEN true
+            ELSE pg_catalog.has_column_privilege ( cols.username::name, cols.oid, cols.attnum, 'INSERT' )
+            END AS can_insert,
+        CASE
+            WHEN cols.username = '' THEN true
+            ELSE pg_catalog.has_column_privilege ( cols.username::name, cols.oid, cols.attnum, 'UPDATE' )
+            END AS can_update,
+        CASE
+            WHEN cols.username = '' THEN true
+            ELSE pg_catalog.has_column_privilege ( cols.username::name, cols.oid, cols.attnum, 'REFERENCES' )
+            END AS can_references
     FROM cols
     LEFT JOIN pk
         ON ( pk.schema_name = cols.schema_name
             AND pk.obj_name = cols.obj_name
             AND pk.column_name = cols.column_name )
-    ORDER BY cols.ordinal_position
-`
+    ORDER BY cols.schema_name,
+        cols.obj_name,
+        cols.ordinal_position
+`, identity, generated)
 
-	rows, err := db.Query(q, schema, objName)
+	rows, err := db.Query(q, schema, objName, user)
 	if err != nil {
 		return
 	}
@@ -208,7 +320,9 @@ SELECT cols.column_name,
 
 	for rows.Next() {
 
-		err = rows.Scan(&u.ColumnName,
+		err = rows.Scan(&u.SchemaName,
+			&u.ObjName,
+			&u.ColumnName,
 			&u.DataType,
 			&u.TypeName,
 			&u.TypeCategory,
@@ -216,6 +330,13 @@ SELECT cols.column_name,
 			&u.IsRequired,
 			&u.IsPk,
 			&u.Description,
+			&u.Identity,
+			&u.Generated,
+			&u.HasDefault,
+			&u.Privileges.Select,
+			&u.Privileges.Insert,
+			&u.Privileges.Update,
+			&u.Privileges.References,
 		)
 		if err != nil {
 			return
@@ -2,24 +2,46 @@ package meta
 
 import (
 	"fmt"
+	"strings"
 	//"github.com/jackc/pgtype"
+
+	u "github.com/gsiems/pg2go/util"
 )
 
-type pgType struct {
-	oid          int
-	schemaName   string
-	typeName     string
-	baseOid      int
-	baseTypeName string
-	typeType     string
-	typeCategory string
+// PgOidTypeMetadata contains the metadata for mapping type OIDs to types
+type PgOidTypeMetadata struct {
+	Oid          int    `db:"oid"`
+	SchemaName   string `db:"schema_name"`
+	TypeName     string `db:"type_name"`
+	DataType     string `db:"data_type"`
+	BaseOid      int    `db:"base_oid"`
+	BaseTypeName string `db:"base_type_name"`
+	TypeType     string `db:"type_type"`
+	TypeCategory string `db:"type_category"`
 }
 
+// The strategies for translating nullable columns
+const (
+	NullStylePgtype  = "pgtype"  // the pgtype types
+	NullStyleSQL     = "sql"     // the database/sql Null types, or pointers when there is no Null type
+	NullStylePointer = "pointer" // pointers to the Go types
+	NullStyleGeneric = "generic" // the generic database/sql Null[T] type
+	NullStyleNone    = "none"    // the Go types only
+)
+
 type Translator struct {
-	userDomains map[string]string
-	userTypes   map[string]*PgUsertypeMetadata
-	pgTypes     map[string]string
-	oidToType   map[int]*pgType
+	nullStyle       string
+	userDomains     map[string]string
+	userEnums       map[string]string
+	domains         map[string]*PgDomainMetadata
+	enums           map[string]*PgEnumMetadata
+	userTypes       map[string]*PgUsertypeMetadata
+	pgTypes         map[string]string
+	oidToType       map[int]*PgOidTypeMetadata
+	typeOverrides   map[string]TypeOverride
+	columnOverrides map[string]TypeOverride
+	functionNames   map[string]string
+	functionCursors map[string][]string
 }
 
 var tc Translator
@@ -101,19 +123,103 @@ func init() {
 	}
 }
 
-func addUserDomain(domainName, pgTypeName string) {
+// SetNullStyle sets the strategy used for translating the types of
+// nullable columns
+func SetNullStyle(style string) error {
+	switch style {
+	case NullStylePgtype, NullStyleSQL, NullStylePointer, NullStyleGeneric, NullStyleNone:
+		tc.nullStyle = style
+		return nil
+	}
+	return fmt.Errorf("Invalid null style %q", style)
+}
+
+// NullStyle returns the strategy used for translating the types of
+// nullable columns
+func NullStyle() string {
+	if tc.nullStyle == "" {
+		return NullStylePgtype
+	}
+	return tc.nullStyle
+}
+
+// PgtypeNames returns the names of the pgtype types that may be used
+// by the generated code
+func PgtypeNames() (d []string) {
+	d = append(d, "EnumArray")
+	for _, v := range tc.pgTypes {
+		d = append(d, strings.TrimPrefix(v, "pgtype."))
+	}
+	return
+}
+
+// usePgtype returns true if the pgtype types are being used
+func usePgtype() bool {
+	return tc.nullStyle == "" || tc.nullStyle == NullStylePgtype
+}
+
+// IsDomain returns true if the Postgresql type name is a domain
+func IsDomain(typeName string) bool {
+	_, ok := tc.userDomains[typeName]
+	return ok
+}
+
+// DomainBaseType returns the Postgresql type name of the base type of a
+// domain
+func DomainBaseType(typeName string) string {
+	return tc.userDomains[typeName]
+}
+
+func addUserDomain(p *PgDomainMetadata) {
 
 	if tc.userDomains == nil {
 		tc.userDomains = make(map[string]string)
 	}
+	if tc.domains == nil {
+		tc.domains = make(map[string]*PgDomainMetadata)
+	}
 
-	tc.userDomains[domainName] = pgTypeName
+	tc.userDomains[p.ObjName] = p.TypeName
+	tc.domains[p.ObjName] = p
 }
 
-func addOidToType(oid int, p *pgType) {
+func addUserEnum(p *PgEnumMetadata) {
+
+	if tc.userEnums == nil {
+		tc.userEnums = make(map[string]string)
+	}
+	if tc.enums == nil {
+		tc.enums = make(map[string]*PgEnumMetadata)
+	}
+
+	tc.userEnums[p.ObjName] = p.GoTypeName
+	tc.enums[p.ObjName] = p
+}
+
+func addUserType(typeName string, p *PgUsertypeMetadata) {
+
+	if tc.userTypes == nil {
+		tc.userTypes = make(map[string]*PgUsertypeMetadata)
+	}
+
+	tc.userTypes[typeName] = p
+}
+
+// resetUserTypes clears the user defined types and the OID to type map
+// so that types from previously read metadata are not carried over
+func resetUserTypes() {
+	tc.userDomains = make(map[string]string)
+	tc.userEnums = make(map[string]string)
+	tc.domains = make(map[string]*PgDomainMetadata)
+	tc.enums = make(map[string]*PgEnumMetadata)
+	tc.userTypes = make(map[string]*PgUsertypeMetadata)
+	tc.oidToType = make(map[int]*PgOidTypeMetadata)
+}
+
+func addOidToType(oid int, p *PgOidTypeMetadata) {
 
 	if tc.oidToType == nil {
-		tc.oidToType = make(map[int]*pgType)
+		tc.oidToType = make(map[int]*PgOidTypeMetadata)
 	}
 
 	tc.oidToType[oid] = p
@@ -130,21 +236,142 @@ func addOidToType(oid int, pgTypeName string) {
 }
 */
 
+// TranslateType returns the Go type for a Postgresql type name. Unless
+// the pgtype null style is used, no pgtype types are used: the built-in
+// types that have a Go equivalent are translated to the Go type, the
+// arrays of built-in types and of enums to the lib/pq array types, and
+// the other built-in types to the string of their text representation.
 func TranslateType(typeName string) (n string, err error) {
 
-	n, ok := tc.pgTypes[typeName]
+	o, ok := tc.typeOverrides[typeName]
 	if ok {
+		n = o.GoType
 		return
 	}
 
+	// polymorphic types are generated as type parameters
+	n, ok = polymorphicTypes[typeName]
+	if ok {
+		return
+	}
+
+	if !usePgtype() {
+		n = u.ToGoVarType(typeName)
+		if n != "" {
+			return
+		}
+		if strings.HasPrefix(typeName, "_") {
+			elem := typeName[1:]
+			_, isPg := tc.pgTypes[typeName]
+			_, isEnum := tc.userEnums[elem]
+			if isPg || isEnum || u.ToGoVarType(elem) != "" {
+				n = u.ToGoArrayType(elem)
+				return
+			}
+		}
+		if _, ok = tc.pgTypes[typeName]; ok {
+			n = "string"
+			return
+		}
+	}
+
+	n, ok = tc.pgTypes[typeName]
+	if ok {
+		return
+	}
+
+	n, ok = tc.userEnums[typeName]
+	if ok {
+		return
+	}
+
+	// composite types are generated as structs
+	t, ok := tc.userTypes[typeName]
+	if ok {
+		n = t.StructName
+		return
+	}
+
+	// arrays of enums and composite types
+	if strings.HasPrefix(typeName, "_") {
+		_, ok = tc.userEnums[typeName[1:]]
+		if ok {
+			n = "pgtype.EnumArray"
+			return
+		}
+		t, ok = tc.userTypes[typeName[1:]]
+		if ok {
+			n = t.StructName + "Array"
+			return
+		}
+	}
+
+	// domains are generated as named types over their base type
 	d, ok := tc.userDomains[typeName]
 	if ok {
-		n, err = TranslateType(d)
+		_, err = TranslateType(d)
 		if err != nil {
 			return
 		}
+		n = u.ToUpperCamelCase(typeName)
+		return
 	}
 
 	err = fmt.Errorf("Unable to translate Pg type name %q", typeName)
 	return
 }
+
+// TranslateDomainBaseType returns the Go type for the base type of a
+// domain. As time.Time has no Scan and Value methods for the domain type
+// to use, the date and time types are sql.NullTime.
+func TranslateDomainBaseType(typeName string) (n string, err error) {
+
+	n, err = TranslateType(typeName)
+	if n == "time.Time" {
+		n = "sql.NullTime"
+	}
+	return
+}
+
+// TranslateColumnType returns the Go type for a column (or argument).
+// The types of nullable columns are determined by the null style unless
+// the column, or its type, has been overridden.
+func TranslateColumnType(col PgColumnMetadata) (n string, err error) {
+
+	o, ok := columnOverride(col)
+	if ok {
+		n = o.GoType
+		if !col.IsRequired && o.NullGoType != "" {
+			n = o.NullGoType
+		}
+		return
+	}
+
+	// The type arguments of polymorphic columns determine their own
+	// handling of NULL values
+	n, err = TranslateType(col.TypeName)
+	if err != nil || col.IsRequired || usePgtype() || IsPolymorphic(col.TypeName) {
+		return
+	}
+
+	// The pgtype types, slices, and the arrays of user types already
+	// support NULL values
+	if strings.HasPrefix(n, "pgtype.") || strings.HasPrefix(n, "[]") || strings.HasPrefix(col.TypeName, "_") {
+		return
+	}
+
+	switch tc.nullStyle {
+	case NullStyleSQL:
+		s := u.ToNullVarType(col.TypeName)
+		if s != "" {
+			n = s
+		} else {
+			n = "*" + n
+		}
+	case NullStylePointer:
+		n = "*" + n
+	case NullStyleGeneric:
+		n = fmt.Sprintf("sql.Null[%s]", n)
+	}
+	return
+}
@@ -1,8 +1,8 @@
 package meta
 
 import (
-	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/lib/pq"
 
@@ -13,40 +13,106 @@ import (
 type PgUsertypeMetadata struct {
 	SchemaName  string `db:"schema_name"`
 	ObjName     string `db:"obj_name"`
+	TypeName    string `db:"type_name"`
 	ObjType     string `db:"obj_type"`
 	Description string `db:"description"`
 	StructName  string
 	Columns     []PgColumnMetadata
 }
 
-// GetTypeMetas returns the metadata for the avaiable user types
-func GetTypeMetas(db *sql.DB, schema, objName, user string, pgVersion int) (types []PgUsertypeMetadata, err error) {
+// GetTypeMetas returns the metadata for the avaiable user types. All of
+// the composite types are loaded into the translator, as the enums and
+// domains are, so that the columns of a type that is not selected by the
+// schema and object names can still be translated (see
+// addReferencedTypes).
+func GetTypeMetas(cat Catalog, schema, objName, user string, pgVersion int) (types []PgUsertypeMetadata, err error) {
 
-	errq := mapOidToType(db)
+	oidTypes, errq := cat.ListOidTypes()
 	if errq != nil {
 		err = fmt.Errorf("Expected oid to type mapping, got error: %q", errq)
 		return
 	}
+	for i, t := range oidTypes {
+		addOidToType(t.Oid, &oidTypes[i])
+	}
 
-	types, errq = listTypeMetas(db, schema, objName)
+	all, errq := cat.ListTypes("", "")
 	if errq != nil {
 		err = fmt.Errorf("Expected type metadata, got error: %q", errq)
 		return
 	}
-	for i, f := range types {
-		types[i].StructName = u.ToUpperCamelCase(f.ObjName)
-		columns, errq := listTypeColumnMetas(db, f.SchemaName, f.ObjName)
-		if errq != nil {
-			err = fmt.Errorf("Expected column metadata for composite types, got error: %q", errq)
-			return
+
+	// The attributes are read for all of the types at once rather than
+	// per type
+	columns, errq := cat.ListTypeColumns("", "")
+	if errq != nil {
+		err = fmt.Errorf("Expected column metadata for composite types, got error: %q", errq)
+		return
+	}
+	colMap := groupColumns(columns)
+
+	for i, f := range all {
+		all[i].StructName = u.ToUpperCamelCase(f.ObjName)
+		all[i].Columns = colMap[objKey(f.SchemaName, f.TypeName)]
+
+		addUserType(f.TypeName, &all[i])
+	}
+
+	for _, f := range all {
+		if inSchema(f.SchemaName, schema) && inObjects(f.ObjName, objName) {
+			types = append(types, f)
+		}
+	}
+	return
+}
+
+// addReferencedTypes adds the composite types that are used by the
+// columns of the types, tables, and functions, but that were not
+// selected by the schema and object names, to the types so that the
+// structs for them are generated.
+func addReferencedTypes(types []PgUsertypeMetadata, tables []PgTableMetadata, funcs []PgFunctionMetadata) []PgUsertypeMetadata {
+
+	seen := make(map[string]bool)
+	for _, t := range types {
+		seen[t.TypeName] = true
+	}
+
+	cols := referencedColumns(types, tables, funcs)
+
+	// The columns of the added types are checked in turn so that nested
+	// types are added
+	for i := 0; i < len(cols); i++ {
+		typeName := strings.TrimPrefix(cols[i].TypeName, "_")
+		t, ok := tc.userTypes[typeName]
+		if !ok || seen[typeName] {
+			continue
 		}
-		types[i].Columns = columns
+		seen[typeName] = true
+		types = append(types, *t)
+		cols = append(cols, t.Columns...)
+	}
+	return types
+}
+
+// referencedColumns returns the columns (and arguments) of the types,
+// tables, and functions
+func referencedColumns(types []PgUsertypeMetadata, tables []PgTableMetadata, funcs []PgFunctionMetadata) (cols []PgColumnMetadata) {
+	for _, t := range types {
+		cols = append(cols, t.Columns...)
+	}
+	for _, t := range tables {
+		cols = append(cols, t.Columns...)
+	}
+	for _, f := range funcs {
+		cols = append(cols, f.ResultColumns...)
+		cols = append(cols, f.ResultRowColumns...)
+		cols = append(cols, f.CallingArguments...)
 	}
 	return
 }
 
 // listTypeMetas returns the list of avaiable user types
-func listTypeMetas(db *sql.DB, schema, objName string) (d []PgUsertypeMetadata, err error) {
+func listTypeMetas(db Queryer, schema, objName string) (d []PgUsertypeMetadata, err error) {
 
 	var u PgUsertypeMetadata
 
@@ -56,6 +122,7 @@ func listTypeMetas(db *sql.DB, schema, objName string) (d []PgUsertypeMetadata,
 )
 SELECT n.nspname::text AS schema_name,
         pg_catalog.format_type ( t.oid, NULL ) AS obj_name,
+        t.typname::text AS type_name,
         CASE
             WHEN t.typrelid != 0 THEN CAST ( 'tuple' AS pg_catalog.text )
             WHEN t.typlen < 0 THEN CAST ( 'var' AS pg_catalog.text )
@@ -97,6 +164,7 @@ SELECT n.nspname::text AS schema_name,
 
 		err = rows.Scan(&u.SchemaName,
 			&u.ObjName,
+			&u.TypeName,
 			&u.ObjType,
 			&u.Description,
 		)
@@ -110,19 +178,21 @@ SELECT n.nspname::text AS schema_name,
 	return
 }
 
-// listTypeColumnMetas returns the metadata for the avaiable user type columns
-func listTypeColumnMetas(db *sql.DB, schema, objName string) (d []PgColumnMetadata, err error) {
+// listTypeColumnMetas returns the metadata for the columns of the
+// avaiable user types. The columns belong to the type name (rather than
+// the formatted type name) of the type.
+func listTypeColumnMetas(db Queryer, schema, objName string) (d []PgColumnMetadata, err error) {
 
 	var u PgColumnMetadata
 
 	q := `
 WITH args AS (
     SELECT $1 AS schema_name,
-            $2 AS obj_name
+            regexp_split_to_table ( $2, ', *' ) AS obj_name
 ),
 cols AS (
     SELECT n.nspname::text AS schema_name,
-            pg_catalog.format_type ( tt.oid, NULL ) AS obj_name,
+            tt.typname::text AS obj_name,
             a.attname::text AS column_name,
             pg_catalog.format_type ( a.atttypid, a.atttypmod ) AS data_type,
             tc.typname AS type_name,
@@ -133,18 +203,28 @@ cols AS (
         FROM pg_catalog.pg_attribute a
         JOIN pg_catalog.pg_type tt
             ON a.attrelid = tt.typrelid
+        JOIN pg_catalog.pg_class c
+            ON ( c.oid = tt.typrelid )
         JOIN pg_catalog.pg_type tc
             ON a.atttypid = tc.oid
         JOIN pg_catalog.pg_namespace n
             ON ( n.oid = tt.typnamespace )
         CROSS JOIN args
         WHERE tt.typtype = 'c'
+            AND c.relkind = 'c'
             AND a.attnum > 0
             AND NOT a.attisdropped
-            AND n.nspname = args.schema_name
-            AND pg_catalog.format_type ( tt.oid, NULL ) = args.obj_name
+            AND n.nspname <> 'pg_catalog'
+            AND n.nspname <> 'information_schema'
+            AND n.nspname !~ '^pg_toast'
+            AND ( n.nspname = args.schema_name
+                OR args.schema_name = '' )
+            AND ( pg_catalog.format_type ( tt.oid, NULL ) = args.obj_name
+                OR coalesce ( args.obj_name, '' ) = '' )
 )
-SELECT cols.column_name,
+SELECT cols.schema_name,
+        cols.obj_name,
+        cols.column_name,
         cols.data_type,
         cols.type_name,
         cols.type_category,
@@ -166,7 +246,9 @@ SELECT cols.column_name,
 
 	for rows.Next() {
 
-		err = rows.Scan(&u.ColumnName,
+		err = rows.Scan(&u.SchemaName,
+			&u.ObjName,
+			&u.ColumnName,
 			&u.DataType,
 			&u.TypeName,
 			&u.TypeCategory,
@@ -185,13 +267,16 @@ SELECT cols.column_name,
 	return
 }
 
-// mapOidToType
-func mapOidToType(db *sql.DB) (err error) {
+// listOidTypeMetas returns the list of types for mapping type OIDs to
+// types. All types are included so that any function argument type can
+// be resolved.
+func listOidTypeMetas(db Queryer) (d []PgOidTypeMetadata, err error) {
 
 	q := `
 SELECT t.oid,
         n.nspname::text AS schema_name,
         t.typname::text AS type_name,
+        pg_catalog.format_type ( t.oid, NULL ) AS data_type,
         coalesce ( bt.oid, 0 ) AS base_oid,
         coalesce ( bt.typname::text, '' ) AS base_type_name,
         t.typtype AS type_type,
@@ -201,12 +286,7 @@ SELECT t.oid,
         ON n.oid = t.typnamespace
     LEFT JOIN pg_catalog.pg_type bt
         ON ( bt.oid = t.typbasetype )
-    WHERE 1 = 1
-        AND n.nspname <> 'information_schema'
-        AND n.nspname !~ '^pg_toast'
-        AND t.typtype NOT IN ( 'p' )
-        AND NOT ( t.typtype = 'c'
-            AND n.nspname = 'pg_catalog' )
+    WHERE n.nspname !~ '^pg_toast'
 `
 
 	rows, err := db.Query(q)
@@ -216,21 +296,22 @@ SELECT t.oid,
 	defer rows.Close()
 
 	for rows.Next() {
-		var u pgType
-
-		err = rows.Scan(&u.oid,
-			&u.schemaName,
-			&u.typeName,
-			&u.baseOid,
-			&u.baseTypeName,
-			&u.typeType,
-			&u.typeCategory,
+		var u PgOidTypeMetadata
+
+		err = rows.Scan(&u.Oid,
+			&u.SchemaName,
+			&u.TypeName,
+			&u.DataType,
+			&u.BaseOid,
+			&u.BaseTypeName,
+			&u.TypeType,
+			&u.TypeCategory,
 		)
 		if err != nil {
 			return
 		}
 
-		addOidToType(u.oid, &u)
+		d = append(d, u)
 	}
 
 	return
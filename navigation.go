@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+// relationKey returns the key for looking up a table by schema and name
+func relationKey(schemaName, objName string) string {
+	return schemaName + "." + objName
+}
+
+// sortedKeys returns the keys of a table map in sorted order
+func sortedKeys(tables map[string]m.PgTableMetadata) (keys []string) {
+	for k := range tables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// countFksTo returns the number of foreign keys of a table that
+// reference the specified table
+func countFksTo(fks []m.PgForeignKeyMetadata, schemaName, objName string) (n int) {
+	for _, fk := range fks {
+		if fk.RefSchemaName == schemaName && fk.RefObjName == objName {
+			n++
+		}
+	}
+	return
+}
+
+// fkColumnsName returns the camel-cased, concatenated, names of a set of
+// foreign key columns for disambiguating navigation method names
+func fkColumnsName(cols []string) string {
+	return u.ToUpperCamelCase(strings.Join(cols, "_"))
+}
+
+// navMethodName ensures that a navigation method name does not collide
+// with the field names or the other methods of a struct
+func navMethodName(name string, f m.PgTableMetadata, used map[string]bool) string {
+
+	for _, col := range f.Columns {
+		if u.ToUpperCamelCase(col.ColumnName) == name {
+			name = "Get" + name
+			break
+		}
+	}
+	for used[name] {
+		name = "Get" + name
+	}
+	used[name] = true
+	return name
+}
+
+// genTableNavigation generates the methods for navigating from a table
+// struct to the table structs related to it by foreign keys
+func genTableNavigation(args cArgs, f m.PgTableMetadata, tables map[string]m.PgTableMetadata, cb *u.LineBuf) (err error) {
+
+	rn := receiverName(f.StructName)
+	used := map[string]bool{
+		"Insert":     true,
+		"SelectByPK": true,
+		"Update":     true,
+		"Delete":     true,
+		"scanFields": true,
+	}
+
+	// Navigate from the referencing (child) row to the referenced (parent) row
+	for _, fk := range f.ForeignKeys {
+		ref, ok := tables[relationKey(fk.RefSchemaName, fk.RefObjName)]
+		if !ok || !ref.Privileges.Select {
+			continue
+		}
+		if !hasColumns(f.Columns, fk.Columns) || !hasColumns(ref.Columns, fk.RefColumns) {
+			continue
+		}
+
+		name := ref.StructName
+		if countFksTo(f.ForeignKeys, fk.RefSchemaName, fk.RefObjName) > 1 {
+			name += "By" + fkColumnsName(fk.Columns)
+		}
+		name = navMethodName(name, f, used)
+
+		cb.Append(fmt.Sprintf("// %s returns the %s.%s row referenced by the %s foreign key", name, ref.SchemaName, ref.ObjName, fk.ConstraintName))
+		cb.Append(fmt.Sprintf("func (%s *%s) %s(ctx context.Context, q Querier) (*%s, error) {", rn, f.StructName, name, ref.StructName))
+		cb.Append("")
+		cb.Append("\tstmt := `SELECT " + columnList(ref.Columns, ",\n        "))
+		cb.Append(fmt.Sprintf("    FROM %s", qualifiedName(ref.SchemaName, ref.ObjName)))
+		cb.Append(fmt.Sprintf("    WHERE %s`", namedPredicateList(fk.RefColumns, 1)))
+		cb.Append("")
+		cb.Append(fmt.Sprintf("\tvar r %s", ref.StructName))
+		cb.Append("\terr := q.QueryRowContext(ctx, stmt,")
+		for _, col := range fk.Columns {
+			cb.Append(fmt.Sprintf("\t\t%s.%s,", rn, u.ToUpperCamelCase(col)))
+		}
+		cb.Append("\t).Scan(r.scanFields()...)")
+		cb.Append("\tif err != nil {")
+		cb.Append("\t\treturn nil, err")
+		cb.Append("\t}")
+		cb.Append("\treturn &r, nil")
+		cb.Append("}")
+		cb.Append("")
+	}
+
+	// Navigate from the referenced (parent) row to the referencing (child) rows
+	for _, key := range sortedKeys(tables) {
+		child := tables[key]
+		if !child.Privileges.Select {
+			continue
+		}
+
+		for _, fk := range child.ForeignKeys {
+			if fk.RefSchemaName != f.SchemaName || fk.RefObjName != f.ObjName {
+				continue
+			}
+			if !hasColumns(child.Columns, fk.Columns) || !hasColumns(f.Columns, fk.RefColumns) {
+				continue
+			}
+
+			// The table names may be singular or plural so the
+			// child rows are named as a list rather than pluralized
+			name := child.StructName + "List"
+			if countFksTo(child.ForeignKeys, f.SchemaName, f.ObjName) > 1 {
+				name += "By" + fkColumnsName(fk.Columns)
+			}
+			name = navMethodName(name, f, used)
+
+			cb.Append(fmt.Sprintf("// %s returns the %s.%s rows that reference the %s by the %s foreign key", name, child.SchemaName, child.ObjName, f.StructName, fk.ConstraintName))
+			cb.Append(fmt.Sprintf("func (%s *%s) %s(ctx context.Context, q Querier) ([]%s, error) {", rn, f.StructName, name, child.StructName))
+			cb.Append("")
+			cb.Append("\tstmt := `SELECT " + columnList(child.Columns, ",\n        "))
+			cb.Append(fmt.Sprintf("    FROM %s", qualifiedName(child.SchemaName, child.ObjName)))
+			cb.Append(fmt.Sprintf("    WHERE %s`", namedPredicateList(fk.Columns, 1)))
+			cb.Append("")
+			cb.Append("\trows, err := q.QueryContext(ctx, stmt,")
+			for _, col := range fk.RefColumns {
+				cb.Append(fmt.Sprintf("\t\t%s.%s,", rn, u.ToUpperCamelCase(col)))
+			}
+			cb.Append("\t)")
+			cb.Append("\tif err != nil {")
+			cb.Append("\t\treturn nil, err")
+			cb.Append("\t}")
+			cb.Append("\tdefer rows.Close()")
+			cb.Append("")
+			cb.Append(fmt.Sprintf("\tvar d []%s", child.StructName))
+			cb.Append("\tfor rows.Next() {")
+			cb.Append(fmt.Sprintf("\t\tvar r %s", child.StructName))
+			cb.Append("\t\terr = rows.Scan(r.scanFields()...)")
+			cb.Append("\t\tif err != nil {")
+			cb.Append("\t\t\treturn nil, err")
+			cb.Append("\t\t}")
+			cb.Append("\t\td = append(d, r)")
+			cb.Append("\t}")
+			cb.Append("\treturn d, rows.Err()")
+			cb.Append("}")
+			cb.Append("")
+		}
+	}
+
+	return
+}
@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 
@@ -13,73 +15,205 @@ import (
 )
 
 type cArgs struct {
-	packageName string
-	schemaName  string
-	objName     string
-	appUser     string
-	dbName      string
-	dbHost      string
-	dbPort      int
-	dbUser      string
-	help        bool
+	packageName      string
+	schemaName       string
+	objName          string
+	appUser          string
+	nullStyle        string
+	noNulls          bool
+	configFile       string
+	verify           bool
+	force            bool
+	fromSnapshot     string
+	outFile          string
+	dbName           string
+	dbHost           string
+	dbPort           int
+	dbUser           string
+	dsn              string
+	service          string
+	sslMode          string
+	sslRootCert      string
+	sslCert          string
+	sslKey           string
+	dbPosition       string
+	statementTimeout time.Duration
+	lockTimeout      time.Duration
+	help             bool
 }
 
 func main() {
 
 	var args cArgs
 
+	// The dump-meta command writes the metadata snapshot rather than
+	// generating code
+	cmdArgs := os.Args[1:]
+	dumpMeta := len(cmdArgs) > 0 && cmdArgs[0] == "dump-meta"
+	if dumpMeta {
+		cmdArgs = cmdArgs[1:]
+	}
+
 	flag.StringVar(&args.packageName, "package", "main", "The package name (defaults to main).")
 
 	flag.StringVar(&args.schemaName, "schema", "", "The database schema to generate structs for (defaults to all).")
 	flag.StringVar(&args.objName, "objects", "", "The comma-separated list of the database objects to generate a structs for (defaults to all).")
 	flag.StringVar(&args.appUser, "app-user", "", "The name of the application user. If specified then only code for those objects that this user has privileges for will be generated.")
-
-	flag.StringVar(&args.dbName, "database", "", "The name of the database to connect to (required).")
-	flag.StringVar(&args.dbHost, "host", "localhost", "The database host to connect to.")
-	flag.IntVar(&args.dbPort, "port", 5432, "The port to connect to.")
-	flag.StringVar(&args.dbUser, "U", "", "The database user to connect as when generating code (required).")
-
-	flag.Parse()
+	flag.StringVar(&args.nullStyle, "null-style", m.NullStylePgtype, "The types to use for nullable columns: pgtype, sql (database/sql Null types), pointer, generic (sql.Null[T]), or none.")
+	flag.BoolVar(&args.noNulls, "no-nulls", false, "Use the bare Go types, with no NULL types, and no pgtype types (same as -null-style none).")
+	flag.StringVar(&args.configFile, "config", "", "The JSON file of Go type overrides for Postgresql types and columns.")
+	flag.BoolVar(&args.verify, "verify", false, "Type-check the generated code before writing it.")
+	flag.BoolVar(&args.force, "force", false, "Write the generated code even if it fails verification.")
+	flag.StringVar(&args.fromSnapshot, "from-snapshot", "", "Generate the code from a metadata snapshot (as written by dump-meta) instead of a database.")
+	flag.StringVar(&args.outFile, "out", "", "The file to write the metadata snapshot to (dump-meta only, defaults to stdout).")
+
+	flag.StringVar(&args.dsn, "dsn", "", "The connection string, as key=value pairs or a postgres:// URL. Flags that are also specified take precedence.")
+	flag.StringVar(&args.dsn, "url", "", "The connection URL (same as -dsn).")
+	flag.StringVar(&args.service, "service", "", "The connection service name from the pg_service.conf file (defaults to PGSERVICE).")
+	flag.StringVar(&args.dbName, "database", "", "The name of the database to connect to (defaults to PGDATABASE, or the user name).")
+	flag.StringVar(&args.dbHost, "host", "", "The database host, or unix socket directory, to connect to (defaults to PGHOST, or localhost).")
+	flag.IntVar(&args.dbPort, "port", 0, "The port to connect to (defaults to PGPORT, or 5432).")
+	flag.StringVar(&args.dbUser, "U", "", "The database user to connect as when generating code (defaults to PGUSER, or the OS user name).")
+	flag.StringVar(&args.sslMode, "sslmode", "", "The SSL mode: disable, require, verify-ca, or verify-full (defaults to PGSSLMODE).")
+	flag.StringVar(&args.sslRootCert, "sslrootcert", "", "The file of the SSL certificate authority certificates (defaults to PGSSLROOTCERT).")
+	flag.StringVar(&args.sslCert, "sslcert", "", "The file of the SSL client certificate (defaults to PGSSLCERT).")
+	flag.StringVar(&args.sslKey, "sslkey", "", "The file of the SSL client key (defaults to PGSSLKEY).")
+	flag.DurationVar(&args.statementTimeout, "statement-timeout", 5*time.Minute, "The statement_timeout for the metadata queries (0 for the server setting).")
+	flag.DurationVar(&args.lockTimeout, "lock-timeout", 10*time.Second, "The lock_timeout for the metadata queries (0 for the server setting).")
+
+	flag.CommandLine.Parse(cmdArgs)
 
 	if args.help {
 		flag.PrintDefaults()
 	}
 
-	if args.dbUser == "" || args.dbName == "" || args.dbHost == "" {
-		fmt.Println("Insufficient connections parameters specified.")
-		flag.PrintDefaults()
+	if args.noNulls {
+		args.nullStyle = m.NullStyleNone
 	}
+	err := m.SetNullStyle(args.nullStyle)
+	u.DieOnErrf("FAILED! %q.\n", err)
 
-	connStr := fmt.Sprintf("user=%s dbname=%s host=%s port=%d", args.dbUser, args.dbName, args.dbHost, args.dbPort)
-
-	dbPool, err := sql.Open("postgres", connStr)
-	u.DieOnErrf("Expected database connection, got error %q.\n", err)
-	defer dbPool.Close()
+	if args.configFile != "" {
+		var cfg config
+		cfg, err = loadConfig(args.configFile)
+		u.DieOnErrf("Expected configuration, got error %q.\n", err)
+		err = m.SetTypeOverrides(cfg.Types, cfg.Columns)
+		u.DieOnErrf("FAILED! %q.\n", err)
+		err = m.SetFunctionNames(cfg.Functions)
+		u.DieOnErrf("FAILED! %q.\n", err)
+		err = m.SetFunctionCursors(cfg.Cursors)
+		u.DieOnErrf("FAILED! %q.\n", err)
+	}
 
-	err = dbPool.Ping()
-	u.DieOnErrf("Expected database ping, got error %q.\n", err)
+	var md m.Snapshot
+	if args.fromSnapshot != "" {
+		var snap m.Snapshot
+		snap, err = m.ReadSnapshot(args.fromSnapshot)
+		u.DieOnErrf("Expected metadata snapshot, got error %q.\n", err)
+
+		// The header reflects where the metadata came from
+		args.dbHost = snap.DbHost
+		args.dbName = snap.DbName
+		args.dbPosition = snap.Position
+		args.schemaName = snap.Schema
+		args.objName = snap.Objects
+		args.appUser = snap.AppUser
+
+		md, err = m.GetSnapshot(m.NewMemCatalog(snap), snap.Schema, snap.Objects, snap.AppUser, snap.PgVersion)
+		u.DieOnErrf("FAILED! %q.\n", err)
+		md.DbHost = snap.DbHost
+		md.DbName = snap.DbName
+		md.Position = snap.Position
+	} else {
+		md, err = getMetadata(args)
+		u.DieOnErrf("FAILED! %q.\n", err)
+		args.dbHost = md.DbHost
+		args.dbName = md.DbName
+		args.dbPosition = md.Position
+	}
 
-	var pgVersion int
-	pgVersion, err = m.DbVersion(dbPool)
-	if err != nil {
-		u.DieOnErrf("Expected database version, got error %q.\n", err)
+	if dumpMeta {
+		err = m.WriteSnapshot(args.outFile, md)
+		u.DieOnErrf("FAILED! %q.\n", err)
+		return
 	}
 
-	types, err := m.GetTypeMetas(dbPool, args.schemaName, args.objName, args.appUser, pgVersion)
+	genQuerierCode(args)
+
+	err = genEnumCode(args, md.Enums)
 	u.DieOnErrf("FAILED! %q.\n", err)
-	err = genTypeCode(args, types)
+	err = genDomainCode(args, md.Domains)
 	u.DieOnErrf("FAILED! %q.\n", err)
 
-	tables, err := m.GetTableMetas(dbPool, args.schemaName, args.objName, args.appUser, pgVersion)
+	err = genTypeCode(args, md.Types)
 	u.DieOnErrf("FAILED! %q.\n", err)
+
+	tables := generatedTables(md.Tables)
 	err = genTableCode(args, tables)
 	u.DieOnErrf("FAILED! %q.\n", err)
 
-	funcs, err := m.GetFunctionMetas(dbPool, args.schemaName, args.objName, args.appUser, pgVersion)
-	u.DieOnErrf("FAILED! %q.\n", err)
-	err = genFunctionCode(args, funcs)
+	err = genFunctionCode(args, md.Functions, rowStructs(tables, md.Types))
 	u.DieOnErrf("FAILED! %q.\n", err)
 
+	genPolymorphicSupportCode(args, md, tables)
+	genCursorSupportCode(args, md.Functions)
+	genInsertSupportCode(args, tables)
+
+	if args.verify {
+		err = verifyPendingFiles(args)
+		u.DieOnErrf("FAILED! %q.\n", err)
+	}
+
+}
+
+// getMetadata reads the metadata for generating the code from the
+// database. All of the metadata is read in a single read only
+// transaction so that it is consistent even if the schema is being
+// changed at the same time.
+func getMetadata(args cArgs) (md m.Snapshot, err error) {
+
+	p, err := connParams(args)
+	if err != nil {
+		err = fmt.Errorf("Expected connection parameters, got error: %w", err)
+		return
+	}
+
+	dbPool, err := sql.Open("postgres", connString(p))
+	if err != nil {
+		err = fmt.Errorf("Expected database connection, got error: %w", err)
+		return
+	}
+	defer dbPool.Close()
+
+	err = dbPool.Ping()
+	if err != nil {
+		err = fmt.Errorf("Expected database ping, got error: %w", err)
+		return
+	}
+
+	pgVersion, err := m.DbVersion(dbPool)
+	if err != nil {
+		err = fmt.Errorf("Expected database version, got error: %w", err)
+		return
+	}
+
+	tx, position, err := m.BeginSession(dbPool, pgVersion, args.statementTimeout, args.lockTimeout)
+	if err != nil {
+		err = fmt.Errorf("Expected read only transaction, got error: %w", err)
+		return
+	}
+	defer tx.Rollback()
+
+	md, err = m.GetSnapshot(m.NewPgCatalog(tx), args.schemaName, args.objName, args.appUser, pgVersion)
+	if err != nil {
+		return
+	}
+	md.DbHost = p["host"]
+	md.DbName = p["dbname"]
+	md.Position = position
+
+	err = tx.Commit()
+	return
 }
 
 func genTypeCode(args cArgs, d []m.PgUsertypeMetadata) (err error) {
@@ -110,21 +244,64 @@ func genTypeCode(args cArgs, d []m.PgUsertypeMetadata) (err error) {
 
 		cb := u.NewLineBuf()
 
-		appendHeader(args, cb)
-
 		errq := genTypeStruct(args, f, cb)
 		if errq != nil {
 			fmt.Printf("Failed to generate code for type %q.%q\n", f.SchemaName, f.ObjName)
 			continue
 		}
 
-		u.WriteFile(args.packageName, f.StructName, cb)
+		genCompositeMethods(args, f, cb)
+
+		writeCode(args, f.StructName, fmt.Sprintf("%s.%s", f.SchemaName, f.TypeName), cb, m.ColumnImports(f.Columns)...)
+	}
+
+	if len(seen) > 0 {
+		genCompositeSupportCode(args)
 	}
 
 	return
 }
 
-func genTableCode(args cArgs, d []m.PgTableMetadata) (err error) {
+// genTableCode generates the structs and methods for the tables. All of
+// the tables are needed for generating the foreign key navigation methods.
+func genTableCode(args cArgs, tables map[string]m.PgTableMetadata) (err error) {
+
+	for _, key := range sortedKeys(tables) {
+		f := tables[key]
+
+		sb := u.NewLineBuf()
+
+		errq := genTableStruct(args, f, sb)
+		if errq != nil {
+			fmt.Printf("Failed to generate code for table %q.%q\n", f.SchemaName, f.ObjName)
+			continue
+		}
+
+		crud := u.NewLineBuf()
+		errq = genTableCrud(args, f, crud)
+		if errq != nil {
+			fmt.Printf("Failed to generate CRUD code for table %q.%q\n", f.SchemaName, f.ObjName)
+			continue
+		}
+
+		errq = genTableNavigation(args, f, tables, crud)
+		if errq != nil {
+			fmt.Printf("Failed to generate navigation code for table %q.%q\n", f.SchemaName, f.ObjName)
+			continue
+		}
+
+		cb := u.NewLineBuf()
+		cb.Extend(sb)
+		cb.Extend(crud)
+
+		writeCode(args, f.StructName, fmt.Sprintf("%s.%s", f.SchemaName, f.ObjName), cb, m.ColumnImports(f.Columns)...)
+	}
+	return
+}
+
+// generatedTables returns the tables, by schema and name, that code is
+// generated for
+func generatedTables(d []m.PgTableMetadata) map[string]m.PgTableMetadata {
 
 	/*
 		If no schema was specified in the calling args then we can
@@ -136,8 +313,11 @@ func genTableCode(args cArgs, d []m.PgTableMetadata) (err error) {
 	*/
 	seen := make(map[string]int)
 
+	tables := make(map[string]m.PgTableMetadata)
 	for _, f := range d {
 
+		// the columns that can not be selected are left out of the struct
+		f.Columns = selectableColumns(f.Columns)
 		if len(f.Columns) == 0 {
 			continue
 		}
@@ -149,79 +329,90 @@ func genTableCode(args cArgs, d []m.PgTableMetadata) (err error) {
 		}
 		seen[f.StructName] = 1
 
-		cb := u.NewLineBuf()
-
-		appendHeader(args, cb)
-
-		errq := genTableStruct(args, f, cb)
+		_, errq := m.GetStructStanzas(f.Columns)
 		if errq != nil {
 			fmt.Printf("Failed to generate code for table %q.%q\n", f.SchemaName, f.ObjName)
 			continue
 		}
 
-		/*
-		   a -> insert
-		   r -> select
-		   w -> update
-		   d -> delete
-		*/
-
-		//err = genTableSelectList(args, f, cb)
-		//if err != nil {
-		//	return
-		//}
-
-		u.WriteFile(args.packageName, f.StructName, cb)
+		tables[relationKey(f.SchemaName, f.ObjName)] = f
 	}
-	return
+	return tables
 }
 
-func genFunctionCode(args cArgs, d []m.PgFunctionMetadata) (err error) {
+func genFunctionCode(args cArgs, d []m.PgFunctionMetadata, rows map[string]rowStruct) (err error) {
 	/*
 		If no schema was specified in the calling args then we can
 		potentially get duplicate structures. We *could* join the
 		schema name and function name for creating the structure name
 		however there is the issue of not fully qualifying references
 		to the type in the Postgresql code (either because it is in the
-		same schema or is in the search_path). It is also possible to
-		have duplicated structures if there is functional overloading
-		in the database... Another problem is if a function returns a
-		TABLE that matches an exiting table or view as it is unknown
-		how to cleanly/automatically recognize that the table/view
-		struct is the same as the function struct.
+		same schema or is in the search_path). Overloaded functions are
+		given distinct names (see the meta package setFunctionNames).
+		Functions that return the row type of a table, view, or
+		composite type use the struct generated for that type (see
+		rowStructs) rather than getting a struct of their own, unless
+		no struct is generated for that type.
 	*/
-	seen := make(map[string]int)
-
 	for _, f := range d {
 
-		// Functions with zero or one return arguments don't require a struct
-		if len(f.ResultColumns) < 2 {
-			continue
+		cb := u.NewLineBuf()
+
+		// Functions with zero or one return arguments don't require a
+		// struct, unless they return refcursors
+		switch {
+		case len(f.Cursors) > 0:
+			errq := genCursorStruct(args, f, rows, cb)
+			if errq != nil {
+				fmt.Printf("Failed to generate code for function %q.%q\n", f.SchemaName, f.ObjName)
+				continue
+			}
+		case f.ResultRowType != "":
+			if _, shared := resultRowStruct(f, rows); !shared {
+				errq := genFunctionStruct(args, f, f.ResultRowColumns, cb)
+				if errq != nil {
+					fmt.Printf("Failed to generate code for function %q.%q\n", f.SchemaName, f.ObjName)
+					continue
+				}
+			}
+		case len(resultColumns(f)) > 1:
+			errq := genFunctionStruct(args, f, f.ResultColumns, cb)
+			if errq != nil {
+				fmt.Printf("Failed to generate code for function %q.%q\n", f.SchemaName, f.ObjName)
+				continue
+			}
 		}
 
-		// ensure the structure has not been generated already
-		_, ok := seen[f.StructName]
-		if ok {
+		errq := genFunctionOptions(args, f, cb)
+		if errq != nil {
+			fmt.Printf("Failed to generate options for function %q.%q\n", f.SchemaName, f.ObjName)
 			continue
 		}
-		seen[f.StructName] = 1
-
-		cb := u.NewLineBuf()
-
-		appendHeader(args, cb)
 
-		errq := genFunctionStruct(args, f, cb)
+		errq = genFunctionWrapper(args, f, rows, cb)
 		if errq != nil {
-			fmt.Printf("Failed to generate code for function %q.%q\n", f.SchemaName, f.ObjName)
+			fmt.Printf("Failed to generate wrapper for function %q.%q\n", f.SchemaName, f.ObjName)
 			continue
 		}
 
-		u.WriteFile(args.packageName, fmt.Sprintf("f%s", f.StructName), cb)
+		var cols []m.PgColumnMetadata
+		switch {
+		case len(f.Cursors) > 0:
+		case f.ResultRowType != "":
+			if _, shared := resultRowStruct(f, rows); !shared {
+				cols = append(cols, f.ResultRowColumns...)
+			}
+		default:
+			cols = append(cols, resultColumns(f)...)
+		}
+		cols = append(cols, f.CallingArguments...)
+
+		writeCode(args, fmt.Sprintf("f%s", f.FuncName), fmt.Sprintf("%s.%s", f.SchemaName, f.ObjName), cb, m.ColumnImports(cols)...)
 	}
 	return
 }
 
-func appendHeader(args cArgs, cb *u.LineBuf) {
+func appendHeader(args cArgs, cb *u.LineBuf, imports ...string) {
 
 	cb.Append(fmt.Sprintf("package %s", args.packageName))
 	cb.Append("")
@@ -229,6 +420,9 @@ func appendHeader(args cArgs, cb *u.LineBuf) {
 	cb.Append(fmt.Sprintf("// Postgresql structs generated for the following:"))
 	cb.Append(fmt.Sprintf("// Host: %s", args.dbHost))
 	cb.Append(fmt.Sprintf("// Database: %s", args.dbName))
+	if args.dbPosition != "" {
+		cb.Append(fmt.Sprintf("// Database snapshot: %s", args.dbPosition))
+	}
 	if args.schemaName != "" {
 		cb.Append(fmt.Sprintf("// Schema: %s", args.schemaName))
 	}
@@ -240,15 +434,22 @@ func appendHeader(args cArgs, cb *u.LineBuf) {
 	}
 
 	cb.Append("")
-	cb.Append("import (")
-	cb.Append("\t\"database/sql\"")
-	cb.Append("\t\"time\"")
-	cb.Append("")
-	cb.Append("\t\"github.com/jackc/pgtype\"")
-	cb.Append("\t_ \"github.com/lib/pq\"")
-	cb.Append(")")
-	cb.Append("")
 
+	std, other := groupImports(imports)
+	if len(std)+len(other) > 0 {
+		cb.Append("import (")
+		for _, s := range std {
+			cb.Append(fmt.Sprintf("\t%q", s))
+		}
+		if len(std) > 0 && len(other) > 0 {
+			cb.Append("")
+		}
+		for _, s := range other {
+			cb.Append(fmt.Sprintf("\t%q", s))
+		}
+		cb.Append(")")
+		cb.Append("")
+	}
 }
 
 func genTypeStruct(args cArgs, f m.PgUsertypeMetadata, cb *u.LineBuf) (err error) {
@@ -265,6 +466,8 @@ func genTypeStruct(args cArgs, f m.PgUsertypeMetadata, cb *u.LineBuf) (err error
 	}
 	cb.Append(fmt.Sprintf("type %s struct {", f.StructName))
 	cb.Append(stanza)
+	cb.Append("")
+	cb.Append(fmt.Sprintf("\t%s bool `json:\"-\" db:\"-\"` // the %s.%s value is NULL", compositeNullField(f), f.SchemaName, f.ObjName))
 	cb.Append("}")
 	cb.Append("")
 	return
@@ -288,10 +491,10 @@ func genTableStruct(args cArgs, f m.PgTableMetadata, cb *u.LineBuf) (err error)
 	return
 }
 
-func genFunctionStruct(args cArgs, f m.PgFunctionMetadata, cb *u.LineBuf) (err error) {
+func genFunctionStruct(args cArgs, f m.PgFunctionMetadata, cols []m.PgColumnMetadata, cb *u.LineBuf) (err error) {
 
 	var stanza string
-	stanza, err = m.GetStructStanzas(f.ResultColumns)
+	stanza, err = m.GetStructStanzas(cols)
 	if err != nil {
 		return
 	}
@@ -300,7 +503,7 @@ func genFunctionStruct(args cArgs, f m.PgFunctionMetadata, cb *u.LineBuf) (err e
 	if f.Description != "" {
 		cb.Append(fmt.Sprintf("// %s", strings.ReplaceAll(f.Description, "\n", "\n// ")))
 	}
-	cb.Append(fmt.Sprintf("type %s struct {", f.StructName))
+	cb.Append(fmt.Sprintf("type %s%s struct {", f.StructName, typeParamDecl(m.TypeParams(cols))))
 	cb.Append(stanza)
 	cb.Append("}")
 	cb.Append("")
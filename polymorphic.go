@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+// typeParamDecl returns the type parameter list for declaring a generic
+// function or struct
+func typeParamDecl(params []string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	var ary []string
+	for _, p := range params {
+		ary = append(ary, p+" any")
+	}
+	return fmt.Sprintf("[%s]", strings.Join(ary, ", "))
+}
+
+// typeArgs returns the type argument list for referring to a generic
+// struct from a function having the same type parameters
+func typeArgs(params []string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[%s]", strings.Join(params, ", "))
+}
+
+// polymorphicCast returns the Go expression for the cast of a polymorphic
+// function argument. The Postgresql type is determined when called, from
+// the type argument, as the database can not resolve the actual type of
+// the polymorphic argument from an untyped bind parameter.
+func polymorphicCast(col m.PgColumnMetadata) string {
+	t, _ := m.TranslateColumnType(col)
+	return fmt.Sprintf("pgCast[%s]()", t)
+}
+
+// hasGenericFunctions returns true if any of the functions are generated
+// as generic functions
+func hasGenericFunctions(d []m.PgFunctionMetadata) bool {
+	for _, f := range d {
+		if len(m.TypeParams(f.CallingArguments)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPolymorphicArrayResults returns true if any of the functions return
+// a polymorphic array
+func hasPolymorphicArrayResults(d []m.PgFunctionMetadata) bool {
+	for _, f := range d {
+		for _, col := range resultColumns(f) {
+			if m.IsPolymorphicArray(col.TypeName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasCompositeTypes returns true if any composite types are generated
+// (see genTypeCode)
+func hasCompositeTypes(d []m.PgUsertypeMetadata) bool {
+	for _, t := range d {
+		if len(t.Columns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// genPolymorphicSupportCode generates the support code for determining
+// the Postgresql types of the arguments to the generic (polymorphic)
+// functions. The Go types generated for the enums, domains, composite
+// types, and tables are mapped to their Postgresql types along with the
+// pgtype, database/sql, and built-in Go types. The polymorphic array
+// results are scanned with the composite support code, which is also
+// generated if there are no composite types.
+func genPolymorphicSupportCode(args cArgs, md m.Snapshot, tables map[string]m.PgTableMetadata) {
+
+	if !hasGenericFunctions(md.Functions) {
+		return
+	}
+
+	arrayResults := hasPolymorphicArrayResults(md.Functions)
+	if arrayResults && !hasCompositeTypes(md.Types) {
+		genCompositeSupportCode(args)
+	}
+
+	names := m.PgtypeCastNames()
+	for k, v := range sqlCastNames {
+		names[k] = v
+	}
+
+	userType := func(goName, schemaName, typeName string) {
+		names[fmt.Sprintf("%s.%s", args.packageName, goName)] = qualifiedName(schemaName, typeName)
+	}
+	for _, e := range md.Enums {
+		userType(e.GoTypeName, e.SchemaName, e.ObjName)
+	}
+	for _, d := range md.Domains {
+		userType(d.GoTypeName, d.SchemaName, d.ObjName)
+	}
+	for _, t := range md.Types {
+		userType(t.StructName, t.SchemaName, t.TypeName)
+	}
+	for _, t := range tables {
+		userType(t.StructName, t.SchemaName, t.ObjName)
+	}
+
+	var keys []string
+	for k := range names {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	cb := u.NewLineBuf()
+
+	for _, s := range polymorphicSupport {
+		cb.Append(s)
+	}
+	if arrayResults {
+		for _, s := range polymorphicArraySupport {
+			cb.Append(s)
+		}
+	}
+
+	cb.Append("// pgTypeNames maps the names of Go types to Postgresql type names")
+	cb.Append("var pgTypeNames = map[string]string{")
+	for _, k := range keys {
+		cb.Append(fmt.Sprintf("\t%q: %q,", k, names[k]))
+	}
+	cb.Append("}")
+
+	writeCode(args, "polymorphicsupport", "", cb)
+}
+
+// sqlCastNames maps the database/sql and time types to the Postgresql
+// type names
+var sqlCastNames = map[string]string{
+	"sql.NullBool":    "boolean",
+	"sql.NullByte":    "smallint",
+	"sql.NullFloat64": "double precision",
+	"sql.NullInt16":   "smallint",
+	"sql.NullInt32":   "integer",
+	"sql.NullInt64":   "bigint",
+	"sql.NullString":  "text",
+	"sql.NullTime":    "timestamp with time zone",
+	"time.Time":       "timestamp with time zone",
+}
+
+var polymorphicSupport = []string{
+	`// pgCast returns the cast to the Postgresql type for the Go type T, for`,
+	`// binding the arguments of polymorphic functions, or an empty string if`,
+	`// the Postgresql type is not known (and is left for the database to`,
+	`// resolve).`,
+	`func pgCast[T any]() string {`,
+	`	n := pgTypeOf(reflect.TypeOf((*T)(nil)).Elem())`,
+	`	if n == "" {`,
+	`		return ""`,
+	`	}`,
+	`	return "::" + n`,
+	`}`,
+	``,
+	`// pgTypeOf returns the Postgresql type name for a Go type, or an empty`,
+	`// string if it is not known`,
+	`func pgTypeOf(t reflect.Type) string {`,
+	``,
+	`	for t.Kind() == reflect.Ptr {`,
+	`		t = t.Elem()`,
+	`	}`,
+	`	n, ok := pgTypeNames[t.String()]`,
+	`	if ok {`,
+	`		return n`,
+	`	}`,
+	``,
+	`	switch t.Kind() {`,
+	`	case reflect.Bool:`,
+	`		return "boolean"`,
+	`	case reflect.Int16:`,
+	`		return "smallint"`,
+	`	case reflect.Int32:`,
+	`		return "integer"`,
+	`	case reflect.Int, reflect.Int64:`,
+	`		return "bigint"`,
+	`	case reflect.Float32:`,
+	`		return "real"`,
+	`	case reflect.Float64:`,
+	`		return "double precision"`,
+	`	case reflect.String:`,
+	`		return "text"`,
+	`	case reflect.Slice:`,
+	`		if t.Elem().Kind() == reflect.Uint8 {`,
+	`			return "bytea"`,
+	`		}`,
+	`		n = pgTypeOf(t.Elem())`,
+	`		if n != "" && t.Elem().Kind() != reflect.Slice {`,
+	`			return n + "[]"`,
+	`		}`,
+	`	}`,
+	`	return ""`,
+	`}`,
+	``,
+}
+
+// polymorphicArraySupport is the support code for scanning polymorphic
+// array results into slices of any element type that scanText supports
+var polymorphicArraySupport = []string{
+	`// anyArray returns the sql.Scanner for scanning a one-dimensional array`,
+	`// value into a slice of T`,
+	`func anyArray[T any](dest *[]T) sql.Scanner {`,
+	`	return anyArrayScanner[T]{dest}`,
+	`}`,
+	``,
+	`// anyArrayScanner scans an array value through the text representation`,
+	`// of its elements`,
+	`type anyArrayScanner[T any] struct {`,
+	`	dest *[]T`,
+	`}`,
+	``,
+	`// Scan implements the sql.Scanner interface`,
+	`func (a anyArrayScanner[T]) Scan(src interface{}) error {`,
+	``,
+	`	elems, err := scanArray(src)`,
+	`	if err != nil {`,
+	`		return err`,
+	`	}`,
+	`	if elems == nil {`,
+	`		*a.dest = nil`,
+	`		return nil`,
+	`	}`,
+	``,
+	`	d := make([]T, len(elems))`,
+	`	for i, e := range elems {`,
+	`		err = scanText(&d[i], e)`,
+	`		if err != nil {`,
+	`			return fmt.Errorf("array element %d: %s", i+1, err)`,
+	`		}`,
+	`	}`,
+	`	*a.dest = d`,
+	`	return nil`,
+	`}`,
+	``,
+}
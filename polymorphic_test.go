@@ -0,0 +1,15 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestPolymorphicArraySupport runs the tests in
+// testdata/polymorphicsupport against the generated support code for
+// scanning polymorphic array results, which uses the composite support
+// code.
+func TestPolymorphicArraySupport(t *testing.T) {
+	code := strings.Join(compositeSupport, "\n") + "\n" + strings.Join(polymorphicArraySupport, "\n")
+	runSupportTests(t, "polymorphicsupport", code)
+}
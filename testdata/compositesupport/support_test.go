@@ -0,0 +1,231 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+// These tests are run against the generated composite support code by
+// TestCompositeSupport (in composite_test.go)
+
+// strs converts the parsed elements to strings, with "<nil>" for NULL
+func strs(elems []*string) []string {
+	var d []string
+	for _, e := range elems {
+		if e == nil {
+			d = append(d, "<nil>")
+		} else {
+			d = append(d, *e)
+		}
+	}
+	return d
+}
+
+func TestScanRecord(t *testing.T) {
+
+	tests := []struct {
+		name string
+		src  interface{}
+		n    int
+		want []string
+	}{
+		{"plain", "(1,abc,2.5)", 3, []string{"1", "abc", "2.5"}},
+		{"bytes", []byte("(1,abc)"), 2, []string{"1", "abc"}},
+		{"quoted comma", `(1,"a,b")`, 2, []string{"1", "a,b"}},
+		{"doubled quote", `(1,"say ""hi""")`, 2, []string{"1", `say "hi"`}},
+		{"backslash escapes", `(1,"a\\b\"c")`, 2, []string{"1", `a\b"c`}},
+		{"quoted parens", `("(x)",")")`, 2, []string{"(x)", ")"}},
+		{"null fields", "(,abc,)", 3, []string{"<nil>", "abc", "<nil>"}},
+		{"empty string", `("",abc)`, 2, []string{"", "abc"}},
+		{"null record", nil, 2, []string{"<nil>", "<nil>"}},
+		{"nested record", `(1,"(2,""x,y"")")`, 2, []string{"1", `(2,"x,y")`}},
+		{"nested array", `(1,"{a,""b c"",NULL}")`, 2, []string{"1", `{a,"b c",NULL}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scanRecord(tt.src, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(strs(got), tt.want) {
+				t.Errorf("got %q, want %q", strs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestScanRecordErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		src  interface{}
+		n    int
+	}{
+		{"not a record", "1,2", 2},
+		{"field count", "(1,2,3)", 2},
+		{"type", 42, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := scanRecord(tt.src, tt.n); err == nil {
+				t.Errorf("expected an error for %v", tt.src)
+			}
+		})
+	}
+}
+
+func TestScanArray(t *testing.T) {
+
+	tests := []struct {
+		name string
+		src  interface{}
+		want []string
+	}{
+		{"plain", "{1,2,3}", []string{"1", "2", "3"}},
+		{"empty", "{}", nil},
+		{"nulls", `{a,NULL,null,"NULL"}`, []string{"a", "<nil>", "<nil>", "NULL"}},
+		{"quoted", `{"a,b","c\"d","e\\f"}`, []string{"a,b", `c"d`, `e\f`}},
+		{"records", `{"(1,\"x y\")","(2,)"}`, []string{`(1,"x y")`, "(2,)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scanArray(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(strs(got), tt.want) {
+				t.Errorf("got %q, want %q", strs(got), tt.want)
+			}
+		})
+	}
+
+	got, err := scanArray(nil)
+	if err != nil || got != nil {
+		t.Errorf("NULL array: got %q, %v, want nil", strs(got), err)
+	}
+}
+
+func TestNestedRoundTrip(t *testing.T) {
+
+	inner, err := valueRecord(2, `x,"y"`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer, err := valueRecord(1, inner, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := scanRecord(outer, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields[0] == nil || *fields[0] != "1" || fields[2] == nil || *fields[2] != "" {
+		t.Fatalf("outer fields: got %q", strs(fields))
+	}
+
+	innerFields, err := scanRecord(*fields[1], 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2", `x,"y"`, "<nil>"}
+	if !reflect.DeepEqual(strs(innerFields), want) {
+		t.Errorf("inner fields: got %q, want %q", strs(innerFields), want)
+	}
+
+	ary, err := valueArray("a b", nil, `c"d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems, err := scanArray(ary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"a b", "<nil>", `c"d`}
+	if !reflect.DeepEqual(strs(elems), want) {
+		t.Errorf("array elements: got %q, want %q", strs(elems), want)
+	}
+}
+
+func TestScanText(t *testing.T) {
+
+	var n int32
+	if err := scanText(&n, strs2ptr("42")); err != nil || n != 42 {
+		t.Errorf("int32: got %d, %v", n, err)
+	}
+
+	var p *string
+	if err := scanText(&p, nil); err != nil || p != nil {
+		t.Errorf("NULL pointer: got %v, %v", p, err)
+	}
+	if err := scanText(&p, strs2ptr("abc")); err != nil || p == nil || *p != "abc" {
+		t.Errorf("pointer: got %v, %v", p, err)
+	}
+
+	var b []byte
+	if err := scanText(&b, strs2ptr(`\x0102`)); err != nil || !reflect.DeepEqual(b, []byte{1, 2}) {
+		t.Errorf("bytea: got %v, %v", b, err)
+	}
+}
+
+func strs2ptr(s string) *string {
+	return &s
+}
+
+// The Address and Contact structs are generated from the composites
+// fixture, without the NULL types, by TestCompositeSupport (app.contact
+// has a nested app.address attribute)
+
+func TestNullCompositeRoundTrip(t *testing.T) {
+
+	var c Contact
+	if err := c.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Null {
+		t.Fatalf("NULL column: expected the Null flag, got %+v", c)
+	}
+	v, err := c.Value()
+	if err != nil || v != nil {
+		t.Errorf("NULL column: got %q, %v, want nil", v, err)
+	}
+}
+
+func TestNullNestedCompositeRoundTrip(t *testing.T) {
+
+	c := Contact{Name: "x", Home: Address{Null: true}}
+	v, err := c.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `("x",,)` {
+		t.Errorf("NULL attribute: got %q, want %q", v, `("x",,)`)
+	}
+
+	var got Contact
+	if err := got.Scan(v); err != nil {
+		t.Fatal(err)
+	}
+	if got.Null || got.Name != "x" || !got.Home.Null || got.Others != nil {
+		t.Errorf("NULL attribute: got %+v", got)
+	}
+
+	again, err := got.Value()
+	if err != nil || again != v {
+		t.Errorf("NULL attribute: wrote back %q, %v, want %q", again, err, v)
+	}
+
+	// a zero value address is not NULL
+	c.Home = Address{}
+	v, err = c.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := scanRecord(v, 3)
+	if err != nil || fields[1] == nil {
+		t.Errorf("zero value attribute: got %q, %v, want a non-NULL address", v, err)
+	}
+}
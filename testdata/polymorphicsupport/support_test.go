@@ -0,0 +1,123 @@
+package model
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// These tests are run against the generated polymorphic array support
+// code by TestPolymorphicArraySupport (in polymorphic_test.go)
+
+func TestScanAnyArray(t *testing.T) {
+
+	var ints []int32
+	err := anyArray(&ints).Scan([]byte("{1,-2,3}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ints, []int32{1, -2, 3}) {
+		t.Errorf("int32: got %v", ints)
+	}
+
+	var floats []float64
+	err = anyArray(&floats).Scan("{1.5,-2,3e3}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(floats, []float64{1.5, -2, 3000}) {
+		t.Errorf("float64: got %v", floats)
+	}
+
+	var bools []bool
+	err = anyArray(&bools).Scan([]byte("{t,f}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bools, []bool{true, false}) {
+		t.Errorf("bool: got %v", bools)
+	}
+
+	var texts []string
+	err = anyArray(&texts).Scan([]byte(`{plain,"with, comma","with \"quotes\"",""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(texts, []string{"plain", "with, comma", `with "quotes"`, ""}) {
+		t.Errorf("string: got %q", texts)
+	}
+
+	var blobs [][]byte
+	err = anyArray(&blobs).Scan([]byte(`{"\\x0102"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(blobs, [][]byte{{1, 2}}) {
+		t.Errorf("bytea: got %v", blobs)
+	}
+
+	var times []time.Time
+	err = anyArray(&times).Scan([]byte(`{"2024-03-01 10:20:30+00","2024-03-01 10:20:30.5-05:30"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC),
+		time.Date(2024, 3, 1, 10, 20, 30, 500000000, time.FixedZone("", -5*3600-30*60)),
+	}
+	if len(times) != len(want) {
+		t.Fatalf("time.Time: got %v", times)
+	}
+	for i := range want {
+		if !times[i].Equal(want[i]) {
+			t.Errorf("time.Time %d: got %v, want %v", i, times[i], want[i])
+		}
+	}
+}
+
+func TestScanAnyArrayNulls(t *testing.T) {
+
+	var ptrs []*int32
+	err := anyArray(&ptrs).Scan([]byte("{1,NULL}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ptrs) != 2 || ptrs[0] == nil || *ptrs[0] != 1 || ptrs[1] != nil {
+		t.Errorf("*int32: got %v", ptrs)
+	}
+
+	// scanners get the NULL elements
+	var nulls []sql.NullInt64
+	err = anyArray(&nulls).Scan([]byte("{NULL,7}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nulls, []sql.NullInt64{{}, {Int64: 7, Valid: true}}) {
+		t.Errorf("sql.NullInt64: got %v", nulls)
+	}
+
+	var empty []int32
+	err = anyArray(&empty).Scan([]byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty == nil || len(empty) != 0 {
+		t.Errorf("empty array: got %#v", empty)
+	}
+
+	null := []int32{1}
+	err = anyArray(&null).Scan(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if null != nil {
+		t.Errorf("NULL array: got %#v", null)
+	}
+
+	var bad []int32
+	err = anyArray(&bad).Scan([]byte("{1,x}"))
+	if err == nil {
+		t.Errorf("expected an error for a non-integer element, got %v", bad)
+	}
+}
@@ -21,13 +21,28 @@ func (b *LineBuf) Append(s string) {
 	b.ary = append(b.ary, s)
 }
 
+// Extend appends the lines of another LineBuf
+func (b *LineBuf) Extend(o *LineBuf) {
+	b.ary = append(b.ary, o.ary...)
+}
+
+// Len returns the number of lines in the LineBuf
+func (b *LineBuf) Len() int {
+	return len(b.ary)
+}
+
+// String returns the lines of the LineBuf joined by newlines
+func (b *LineBuf) String() string {
+	return strings.Join(b.ary, "\n")
+}
+
 func WriteFile(dir, filename string, b *LineBuf) {
 
 	f := OpenOutputFile(dir, fmt.Sprintf("%s.go", filename))
 	defer FileClose(f)
 	w := bufio.NewWriter(f)
 
-	_, err := w.Write([]byte(strings.Join(b.ary, "\n")))
+	_, err := w.Write([]byte(b.String()))
 	DieOnErrf("Write failed: %q", err)
 
 	w.Flush()
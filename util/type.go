@@ -1,95 +1,111 @@
 package util
 
-import (
-	"strings"
-)
-
+// ToNullVarType returns the database/sql Null type for a Postgresql type
+// name, or an empty string if database/sql has no Null type for it
 func ToNullVarType(pgV string) string {
 
-	switch pgV {
-	case "date", "time", "interval":
-		return "sql.NullTime"
-
-	case "boolean":
-		return "sql.NullBool"
-
-	case "int", "smallint", "integer":
-		return "sql.NullInt32"
-
-	case "bigint":
-		return "sql.NullInt64"
-
-	case "float4", "float8", "numeric", "money":
-		return "sql.NullFloat"
-
+	s := ToIntVarType(pgV)
+	if s == "" {
+		return ""
 	}
-
-	if strings.Contains(pgV, "timestamp") {
-		return "sql.NullTime"
-	}
-
-	return "sql.NullString"
+	return "sql.Null" + s
 }
 
+// ToIntVarType returns the suffix of the database/sql Null type for a
+// Postgresql type name, or an empty string if database/sql has no Null
+// type for it
 func ToIntVarType(pgV string) string {
 
 	switch pgV {
-	case "date", "time", "interval":
+	case "date", "time", "timetz", "timestamp", "timestamptz":
 		return "Time"
 
-	case "boolean":
+	case "bool":
 		return "Bool"
 
-	case "int", "smallint", "integer":
+	case "int2":
+		return "Int16"
+
+	case "int4":
 		return "Int32"
 
-	case "bigint":
+	case "int8":
 		return "Int64"
 
-	case "float8", "numeric", "money":
+	case "float8":
 		return "Float64"
 
-	case "float4":
-		return "Float32"
+	case "bpchar", "char", "interval", "json", "jsonb", "name", "numeric", "text", "uuid", "varchar", "xml":
+		return "String"
 
 	}
 
-	if strings.Contains(pgV, "timestamp") {
-		return "Time"
-	}
-
-	return "String"
+	return ""
 }
 
+// ToGoVarType returns the Go type for a Postgresql type name, or an empty
+// string if there is no Go type for it. Numeric is a string as float64
+// would lose precision.
 func ToGoVarType(pgV string) string {
 
 	switch pgV {
-	case "date", "time", "interval":
+	case "date", "time", "timetz", "timestamp", "timestamptz":
 		return "time.Time"
 
-	case "boolean":
+	case "bool":
 		return "bool"
 
-	case "smallint":
-		return "int"
+	case "int2":
+		return "int16"
 
-	case "int", "integer":
+	case "int4":
 		return "int32"
 
-	case "bigint":
+	case "int8":
 		return "int64"
 
 	case "float4":
 		return "float32"
 
-	case "float8", "numeric", "money":
+	case "float8":
 		return "float64"
 
+	case "bpchar", "char", "interval", "json", "jsonb", "name", "numeric", "text", "uuid", "varchar", "xml":
+		return "string"
+
+	case "bytea":
+		return "[]byte"
+
 	}
 
-	if strings.Contains(pgV, "timestamp") {
-		return "time.Time"
+	return ""
+}
+
+// ToGoArrayType returns the lib/pq array type for the element type name
+// of a Postgresql array. The elements that have no lib/pq array type are
+// read and written as strings.
+func ToGoArrayType(pgV string) string {
+
+	switch pgV {
+	case "bool":
+		return "pq.BoolArray"
+
+	case "bytea":
+		return "pq.ByteaArray"
+
+	case "int2", "int4":
+		return "pq.Int32Array"
+
+	case "int8":
+		return "pq.Int64Array"
+
+	case "float4":
+		return "pq.Float32Array"
+
+	case "float8":
+		return "pq.Float64Array"
+
 	}
 
-	return "string"
+	return "pq.StringArray"
 }
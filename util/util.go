@@ -4,11 +4,21 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"unicode"
 )
 
+// identParts splits a Postgresql name into the parts to camel-case. Any
+// character that can not be used in a Go identifier separates the parts
+// so that quoted names also give valid identifiers.
+func identParts(pgV string) []string {
+	return strings.FieldsFunc(pgV, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
 func ToUpperCamelCase(pgV string) string {
 
-	ary := strings.Split(pgV, "_")
+	ary := identParts(pgV)
 	for i, v := range ary {
 		switch strings.ToLower(v) {
 		case "id", "html", "json":
@@ -22,7 +32,7 @@ func ToUpperCamelCase(pgV string) string {
 
 func ToLowerCamelCase(pgV string) string {
 
-	ary := strings.Split(pgV, "_")
+	ary := identParts(pgV)
 	for i, v := range ary {
 		if i == 0 {
 			ary[i] = strings.ToLower(v)
@@ -54,3 +64,43 @@ func DieOnErr(err error) {
 		log.Fatal(err)
 	}
 }
+
+// pgReserved is the list of Postgresql reserved key words that need
+// quoting when used as identifiers
+var pgReserved = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true,
+	"array": true, "as": true, "asc": true, "asymmetric": true, "both": true,
+	"case": true, "cast": true, "check": true, "collate": true, "column": true,
+	"constraint": true, "create": true, "current_catalog": true, "current_date": true,
+	"current_role": true, "current_time": true, "current_timestamp": true,
+	"current_user": true, "default": true, "deferrable": true, "desc": true,
+	"distinct": true, "do": true, "else": true, "end": true, "except": true,
+	"false": true, "fetch": true, "for": true, "foreign": true, "from": true,
+	"grant": true, "group": true, "having": true, "in": true, "initially": true,
+	"intersect": true, "into": true, "lateral": true, "leading": true, "limit": true,
+	"localtime": true, "localtimestamp": true, "not": true, "null": true,
+	"offset": true, "on": true, "only": true, "or": true, "order": true,
+	"placing": true, "primary": true, "references": true, "returning": true,
+	"select": true, "session_user": true, "some": true, "symmetric": true,
+	"table": true, "then": true, "to": true, "trailing": true, "true": true,
+	"union": true, "unique": true, "user": true, "using": true, "variadic": true,
+	"when": true, "where": true, "window": true, "with": true,
+}
+
+// QuoteIdent returns the Postgresql identifier, quoted if needed
+func QuoteIdent(s string) string {
+
+	if pgReserved[s] {
+		return `"` + s + `"`
+	}
+
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r == '_':
+		case i > 0 && (r >= '0' && r <= '9' || r == '$'):
+		default:
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		}
+	}
+	return s
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	m "github.com/gsiems/pg2go/meta"
+)
+
+// genFile is a generated file that is held for verification
+type genFile struct {
+	fileName string
+	objName  string // the database object that the file was generated for
+	src      []byte
+}
+
+var pendingFiles []genFile
+
+var (
+	reDbTag       = regexp.MustCompile(`db:"([^"]+)"`)
+	reVersionPart = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+// stubImporter imports the standard library packages normally and
+// returns stub packages for everything else
+type stubImporter struct {
+	std   types.Importer
+	stubs map[string]*types.Package
+}
+
+func (s stubImporter) Import(importPath string) (*types.Package, error) {
+	p, ok := s.stubs[importPath]
+	if ok {
+		return p, nil
+	}
+	return s.std.Import(importPath)
+}
+
+// verifyPendingFiles type-checks the pending generated files and, if
+// there are no errors (or the writing is being forced), writes them
+func verifyPendingFiles(args cArgs) (err error) {
+
+	msgs := verifyFiles(args, pendingFiles)
+	for _, s := range msgs {
+		fmt.Println(s)
+	}
+
+	if len(msgs) > 0 && !args.force {
+		err = fmt.Errorf("Verification of the generated code failed with %d errors, no files were written (use -force to write them anyway)", len(msgs))
+		return
+	}
+
+	for _, f := range pendingFiles {
+		writeFile(args, f.fileName, f.src)
+	}
+	return
+}
+
+// verifyFiles parses and type-checks the generated files as a package,
+// using stub declarations for the non-standard library packages, and
+// returns the errors found
+func verifyFiles(args cArgs, d []genFile) (msgs []string) {
+
+	fset := token.NewFileSet()
+	objNames := make(map[string]string)
+
+	var files []*ast.File
+	for _, g := range d {
+		fileName := g.fileName + ".go"
+		objNames[fileName] = g.objName
+
+		f, err := parser.ParseFile(fset, fileName, g.src, 0)
+		if err != nil {
+			msgs = append(msgs, verifyMessage(fileName, g.objName, "", err.Error()))
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(msgs) > 0 {
+		return
+	}
+
+	std := importer.Default()
+	stubs, err := stubPackages(files, std)
+	if err != nil {
+		msgs = append(msgs, fmt.Sprintf("Unable to create the stub packages: %s", err))
+		return
+	}
+
+	conf := types.Config{
+		Importer: stubImporter{std: std, stubs: stubs},
+		Error: func(err error) {
+			te, ok := err.(types.Error)
+			if !ok {
+				msgs = append(msgs, err.Error())
+				return
+			}
+			pos := te.Fset.Position(te.Pos)
+			msgs = append(msgs, verifyMessage(pos.Filename, objNames[pos.Filename], sourceLine(d, pos), fmt.Sprintf("%d:%d: %s", pos.Line, pos.Column, te.Msg)))
+		},
+	}
+	conf.Check(args.packageName, fset, files, nil)
+
+	return
+}
+
+// verifyMessage formats a verification error, identifying the database
+// object, and column if known, that the failing code was generated for
+func verifyMessage(fileName, objName, line, msg string) string {
+
+	var ary []string
+	if objName != "" {
+		ary = append(ary, objName)
+	}
+
+	mt := reDbTag.FindStringSubmatch(line)
+	if mt != nil {
+		ary = append(ary, fmt.Sprintf("column %s", mt[1]))
+	}
+
+	if len(ary) == 0 {
+		return fmt.Sprintf("Verification failed: %s:%s", fileName, msg)
+	}
+	return fmt.Sprintf("Verification failed for %s: %s:%s", strings.Join(ary, " "), fileName, msg)
+}
+
+// sourceLine returns the line of generated source for a position
+func sourceLine(d []genFile, pos token.Position) string {
+	for _, g := range d {
+		if g.fileName+".go" != pos.Filename {
+			continue
+		}
+		lines := strings.Split(string(g.src), "\n")
+		if pos.Line > 0 && pos.Line <= len(lines) {
+			return lines[pos.Line-1]
+		}
+	}
+	return ""
+}
+
+// importName returns the package name for an import path, assuming that
+// it is the last element of the path that is not a major version
+func importName(importPath string) string {
+	dir, name := path.Split(importPath)
+	if reVersionPart.MatchString(name) && dir != "" {
+		name = path.Base(dir)
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// pqArrayTypes are the lib/pq array types, by the element type, that the
+// columns may be translated to
+var pqArrayTypes = map[string]string{
+	"BoolArray":    "bool",
+	"ByteaArray":   "[]byte",
+	"Float32Array": "float32",
+	"Float64Array": "float64",
+	"Int32Array":   "int32",
+	"Int64Array":   "int64",
+	"StringArray":  "string",
+}
+
+// stubPackages creates the stub packages for the non-standard library
+// imports of the files. The pgtype stub declares the known pgtype types,
+// and the Status constants, so that a mis-translated type is reported. Any other package declares
+// the names that the files refer to as types that implement sql.Scanner
+// and driver.Valuer.
+func stubPackages(files []*ast.File, std types.Importer) (stubs map[string]*types.Package, err error) {
+
+	names := make(map[string]map[string]int)
+
+	for _, f := range files {
+		byName := make(map[string]string)
+		for _, spec := range f.Imports {
+			p, _ := strconv.Unquote(spec.Path.Value)
+			if !strings.Contains(strings.Split(p, "/")[0], ".") {
+				continue
+			}
+			n := importName(p)
+			if spec.Name != nil {
+				n = spec.Name.Name
+			}
+			byName[n] = p
+			if names[p] == nil {
+				names[p] = make(map[string]int)
+			}
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			se, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			id, ok := se.X.(*ast.Ident)
+			if !ok || id.Obj != nil {
+				return true
+			}
+			p, ok := byName[id.Name]
+			if ok {
+				names[p][se.Sel.Name] = 1
+			}
+			return true
+		})
+	}
+
+	stubs = make(map[string]*types.Package)
+	conf := types.Config{Importer: std}
+
+	for p, used := range names {
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "package %s\n\nimport \"database/sql/driver\"\n\nvar _ driver.Value\n\n", importName(p))
+
+		var decls []string
+		switch p {
+		case "github.com/jackc/pgtype":
+			b.WriteString("type Status byte\n\nconst (\n\tUndefined Status = iota\n\tNull\n\tPresent\n)\n\n")
+			decls = m.PgtypeNames()
+		case "github.com/lib/pq":
+			b.WriteString("func Array(a interface{}) interface {\n\tScan(src interface{}) error\n\tValue() (driver.Value, error)\n} {\n\treturn nil\n}\n\n")
+			b.WriteString("func QuoteIdentifier(name string) string {\n\treturn name\n}\n\n")
+			for n, elem := range pqArrayTypes {
+				fmt.Fprintf(&b, "type %s []%s\n\n", n, elem)
+				fmt.Fprintf(&b, "func (*%s) Scan(src interface{}) error { return nil }\n\n", n)
+				fmt.Fprintf(&b, "func (%s) Value() (driver.Value, error) { return nil, nil }\n\n", n)
+			}
+		default:
+			for n := range used {
+				decls = append(decls, n)
+			}
+		}
+		sort.Strings(decls)
+
+		for _, n := range decls {
+			fmt.Fprintf(&b, "type %s struct{}\n\n", n)
+			fmt.Fprintf(&b, "func (*%s) Scan(src interface{}) error { return nil }\n\n", n)
+			fmt.Fprintf(&b, "func (%s) Value() (driver.Value, error) { return nil, nil }\n\n", n)
+		}
+
+		fset := token.NewFileSet()
+		f, errq := parser.ParseFile(fset, p+".go", b.String(), 0)
+		if errq != nil {
+			err = fmt.Errorf("%s: %s", p, errq)
+			return
+		}
+		stubs[p], errq = conf.Check(p, fset, []*ast.File{f}, nil)
+		if errq != nil {
+			err = fmt.Errorf("%s: %s", p, errq)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	m "github.com/gsiems/pg2go/meta"
+	u "github.com/gsiems/pg2go/util"
+)
+
+// supportNames are the names declared by the generated support code
+// (such as pgCast or insertStmt), along with those written by the
+// domain code and the scanFields method of the table structs
+var supportNames = declaredNames(
+	compositeSupport,
+	polymorphicSupport,
+	polymorphicArraySupport,
+	cursorSupport,
+	insertSupport,
+	isNullSupport,
+	isNullPgtypeSupport,
+	[]string{
+		"var pgTypeNames map[string]string",
+		"func domainString() {}",
+		"func domainNumber() {}",
+		"func domainLength() {}",
+		"func domainIn() {}",
+		"func scanFields() {}",
+	},
+)
+
+// declaredNames returns the names of the top level declarations of the
+// support code
+func declaredNames(code ...[]string) map[string]bool {
+
+	d := make(map[string]bool)
+	for _, c := range code {
+		f, err := parser.ParseFile(token.NewFileSet(), "", "package p\n\n"+strings.Join(c, "\n"), 0)
+		if err != nil {
+			panic(err)
+		}
+		for name := range f.Scope.Objects {
+			d[name] = true
+		}
+	}
+	return d
+}
+
+// paramName returns the Go parameter name to use for a function argument.
+// Names that would shadow the variables of the generated wrappers, the
+// support code, or the packages that the generated code uses (such as pq)
+// get an "Arg" suffix.
+func paramName(col m.PgColumnMetadata) string {
+
+	n := u.ToLowerCamelCase(col.ColumnName)
+
+	_, isPackage := knownImports[n]
+	switch {
+	case n == "ctx", n == "q", n == "stmt", n == "rows", n == "err", n == "d", n == "r",
+		n == "args", n == "params", n == "opts", n == "tx", n == "cursors":
+		return n + "Arg"
+	case isPackage, supportNames[n], token.IsKeyword(n):
+		return n + "Arg"
+	}
+	return n
+}
+
+// paramType returns the Go type for a function argument. A VARIADIC
+// argument is a Go variadic parameter of the array element type.
+func paramType(col m.PgColumnMetadata) (string, error) {
+
+	if col.ArgMode != "v" {
+		return m.TranslateColumnType(col)
+	}
+
+	switch {
+	case col.TypeName == "anyarray":
+		col.TypeName = "anyelement"
+	case col.TypeName == "anycompatiblearray":
+		col.TypeName = "anycompatible"
+	case strings.HasPrefix(col.TypeName, "_"):
+		col.TypeName = col.TypeName[1:]
+	default:
+		return "", fmt.Errorf("Unsupported VARIADIC type %q", col.DataType)
+	}
+	col.DataType = strings.TrimSuffix(col.DataType, "[]")
+	col.IsRequired = true
+
+	n, err := m.TranslateColumnType(col)
+	return "..." + n, err
+}
+
+// callArg returns the Go expression for passing a function argument. A
+// VARIADIC, or polymorphic array, argument is passed as an array.
+func callArg(col m.PgColumnMetadata) string {
+	if col.ArgMode == "v" || m.IsPolymorphicArray(col.TypeName) {
+		return fmt.Sprintf("pq.Array(%s)", paramName(col))
+	}
+	return paramName(col)
+}
+
+// resultColumns returns the result columns for a function, less any
+// void result
+func resultColumns(f m.PgFunctionMetadata) []m.PgColumnMetadata {
+	if len(f.ResultColumns) == 1 && f.ResultColumns[0].TypeName == "void" {
+		return nil
+	}
+	return f.ResultColumns
+}
+
+// rowStruct is a generated table, view, or composite type struct that
+// the results of a function can be scanned into
+type rowStruct struct {
+	StructName string
+	Columns    []m.PgColumnMetadata
+}
+
+// rowStructs returns the generated table, view, and composite type
+// structs keyed by schema and type name
+func rowStructs(tables map[string]m.PgTableMetadata, types []m.PgUsertypeMetadata) map[string]rowStruct {
+
+	rows := make(map[string]rowStruct)
+	seen := make(map[string]int)
+
+	for _, f := range types {
+		if len(f.Columns) == 0 {
+			continue
+		}
+		if _, ok := seen[f.StructName]; ok {
+			continue
+		}
+		seen[f.StructName] = 1
+		rows[relationKey(f.SchemaName, f.TypeName)] = rowStruct{f.StructName, f.Columns}
+	}
+
+	for k, f := range tables {
+		rows[k] = rowStruct{f.StructName, f.Columns}
+	}
+	return rows
+}
+
+// resultRowStruct returns the struct for the rows of a function that
+// returns a table, view, or composite type row. This is the struct
+// generated for the type when there is one, otherwise it is the struct
+// generated for the function from the columns of the type (as when the
+// table is outside of the -schema or -objects selection).
+func resultRowStruct(f m.PgFunctionMetadata, rows map[string]rowStruct) (rs rowStruct, shared bool) {
+	rs, shared = rows[f.ResultRowType]
+	if !shared {
+		rs = rowStruct{f.StructName, f.ResultRowColumns}
+	}
+	return
+}
+
+// genFunctionWrapper generates the Go function for calling a database
+// function or procedure
+func genFunctionWrapper(args cArgs, f m.PgFunctionMetadata, rows map[string]rowStruct, cb *u.LineBuf) (err error) {
+
+	results := resultColumns(f)
+	required, optional := optionalArguments(f)
+
+	// Polymorphic functions are generated as generic functions
+	typeParams := m.TypeParams(append(append([]m.PgColumnMetadata{}, f.CallingArguments...), results...))
+
+	var params []string
+	for _, col := range required {
+		var varType string
+		varType, err = paramType(col)
+		if err != nil {
+			err = fmt.Errorf("genFunctionWrapper - %s: %s", col.ColumnName, err)
+			return
+		}
+		params = append(params, fmt.Sprintf("%s %s", paramName(col), varType))
+	}
+	if len(optional) > 0 {
+		params = append(params, fmt.Sprintf("opts %s%s", optionsStructName(f), typeArgs(m.TypeParams(optional))))
+	}
+
+	// Determine what is returned: nothing, a scalar, or a struct (and
+	// either one row or a slice of rows). Functions that return a table,
+	// view, or composite type row use the struct for that type, or their
+	// own struct of the columns of that type, and select the columns of
+	// the struct. Functions that return declared
+	// refcursors return the struct of the rows fetched from the cursors.
+	var resultType string
+	var scans string
+	var selectList string
+	switch {
+	case len(f.Cursors) > 0:
+		resultType = f.StructName
+	case f.ResultRowType != "":
+		rs, _ := resultRowStruct(f, rows)
+		if len(rs.Columns) == 0 {
+			err = fmt.Errorf("genFunctionWrapper - no columns for the %s result type", f.ResultRowType)
+			return
+		}
+		resultType = rs.StructName
+		scans = fieldScanList(rs.Columns, "&r")
+		selectList = columnList(rs.Columns, ",\n        ")
+	case len(results) == 0:
+	case len(results) == 1:
+		resultType, err = m.TranslateColumnType(results[0])
+		if err != nil {
+			err = fmt.Errorf("genFunctionWrapper - %s: %s", results[0].ColumnName, err)
+			return
+		}
+		scans = scanDest("&r", results[0])
+	default:
+		resultType = f.StructName + typeArgs(m.TypeParams(results))
+		scans = fieldScanList(results, "&r")
+	}
+
+	var returns string
+	switch {
+	case resultType == "":
+		returns = "error"
+	case f.ReturnsSet && len(f.Cursors) == 0:
+		returns = fmt.Sprintf("([]%s, error)", resultType)
+	default:
+		returns = fmt.Sprintf("(%s, error)", resultType)
+	}
+
+	// The arguments of polymorphic functions are cast to the Postgresql
+	// type of the type arguments
+	var casts []string
+	for _, col := range f.CallingArguments {
+		if m.IsPolymorphic(col.TypeName) {
+			casts = append(casts, polymorphicCast(col))
+		}
+	}
+
+	// Functions having optional arguments build the argument list, using
+	// named notation for the optional arguments, when called. The
+	// statements that are formatted when called need any "%" escaped.
+	qn := qualifiedName(f.SchemaName, f.ObjName)
+	if len(optional) > 0 || len(casts) > 0 {
+		qn = strings.ReplaceAll(qn, "%", "%%")
+		selectList = strings.ReplaceAll(selectList, "%", "%%")
+	}
+
+	callee := fmt.Sprintf("%s %s", qn, argList(callPlaceholderList(f)))
+	callArgs := "stmt"
+	callCols := f.CallingArguments
+	if len(optional) > 0 {
+		callee = qn + " ( %s )"
+		callArgs = "stmt, args..."
+		callCols = nil
+	}
+
+	var stmt string
+	switch {
+	case f.ObjKind == "p":
+		stmt = fmt.Sprintf("CALL %s %s", qn, argList(procedureArgList(f)))
+	case f.ResultRowType != "":
+		stmt = fmt.Sprintf("SELECT %s\n    FROM %s", selectList, callee)
+	default:
+		stmt = fmt.Sprintf("SELECT * FROM %s", callee)
+	}
+
+	cb.Append(fmt.Sprintf("// %s calls the %s.%s %s", f.FuncName, f.SchemaName, f.ObjName, f.ObjType))
+	if f.Description != "" {
+		cb.Append(fmt.Sprintf("// %s", strings.ReplaceAll(f.Description, "\n", "\n// ")))
+	}
+	cb.Append(fmt.Sprintf("func %s%s(%s) %s {", f.FuncName, typeParamDecl(typeParams), strings.Join(append([]string{"ctx context.Context", "q Querier"}, params...), ", "), returns))
+	cb.Append("")
+	switch {
+	case len(optional) > 0:
+		appendOptionalArgs(f, required, optional, cb)
+		cb.Append(fmt.Sprintf("\tstmt := fmt.Sprintf(`%s`, strings.Join(params, \", \"))", stmt))
+	case len(casts) > 0:
+		cb.Append(fmt.Sprintf("\tstmt := fmt.Sprintf(`%s`, %s)", stmt, strings.Join(casts, ", ")))
+	default:
+		cb.Append(fmt.Sprintf("\tstmt := `%s`", stmt))
+	}
+	cb.Append("")
+
+	switch {
+	case len(f.Cursors) > 0:
+		err = appendCursorCall(f, rows, callArgs, callCols, cb)
+		if err != nil {
+			return
+		}
+
+	case resultType == "":
+		appendCall("\t_, err := q.ExecContext(ctx, "+callArgs, callCols, ")", cb)
+		cb.Append("\treturn err")
+
+	case f.ReturnsSet:
+		appendCall("\trows, err := q.QueryContext(ctx, "+callArgs, callCols, ")", cb)
+		cb.Append("\tif err != nil {")
+		cb.Append("\t\treturn nil, err")
+		cb.Append("\t}")
+		cb.Append("\tdefer rows.Close()")
+		cb.Append("")
+		cb.Append(fmt.Sprintf("\tvar d []%s", resultType))
+		cb.Append("\tfor rows.Next() {")
+		cb.Append(fmt.Sprintf("\t\tvar r %s", resultType))
+		cb.Append(fmt.Sprintf("\t\terr = rows.Scan(%s)", scans))
+		cb.Append("\t\tif err != nil {")
+		cb.Append("\t\t\treturn nil, err")
+		cb.Append("\t\t}")
+		cb.Append("\t\td = append(d, r)")
+		cb.Append("\t}")
+		cb.Append("\treturn d, rows.Err()")
+
+	default:
+		cb.Append(fmt.Sprintf("\tvar r %s", resultType))
+		appendCall("\terr := q.QueryRowContext(ctx, "+callArgs, callCols, fmt.Sprintf(").Scan(%s)", scans), cb)
+		cb.Append("\treturn r, err")
+	}
+
+	cb.Append("}")
+	cb.Append("")
+	return
+}
+
+// procedureArgList returns the argument list for calling a procedure.
+// Output arguments are passed as NULL
+func procedureArgList(f m.PgFunctionMetadata) string {
+
+	// INOUT arguments are both calling arguments and result columns
+	var cols []m.PgColumnMetadata
+	seen := make(map[int]bool)
+	for _, col := range append(append([]m.PgColumnMetadata{}, f.CallingArguments...), resultColumns(f)...) {
+		if !seen[col.OrdinalPosition] {
+			seen[col.OrdinalPosition] = true
+			cols = append(cols, col)
+		}
+	}
+	sort.Slice(cols, func(i, j int) bool { return cols[i].OrdinalPosition < cols[j].OrdinalPosition })
+
+	isInput := make(map[int]bool)
+	for _, col := range f.CallingArguments {
+		isInput[col.OrdinalPosition] = true
+	}
+
+	var ary []string
+	var n int
+	for _, col := range cols {
+		if isInput[col.OrdinalPosition] {
+			n++
+			ary = append(ary, callPlaceholder(f, col, n))
+		} else {
+			ary = append(ary, "NULL")
+		}
+	}
+	return strings.Join(ary, ", ")
+}
+
+// callPlaceholderList returns the bind placeholders for calling a
+// function with its input arguments
+func callPlaceholderList(f m.PgFunctionMetadata) string {
+	var ary []string
+	for i, col := range f.CallingArguments {
+		ary = append(ary, callPlaceholder(f, col, i+1))
+	}
+	return strings.Join(ary, ", ")
+}
+
+// callPlaceholder returns the $n bind placeholder for a function
+// argument. The placeholders for overloaded functions are cast to the
+// argument type so that the database resolves the call to the intended
+// overload rather than to the one preferred for an untyped parameter.
+func callPlaceholder(f m.PgFunctionMetadata, col m.PgColumnMetadata, n int) string {
+	if col.ArgMode == "v" {
+		return fmt.Sprintf("VARIADIC $%d%s", n, placeholderCast(f, col))
+	}
+	return fmt.Sprintf("$%d%s", n, placeholderCast(f, col))
+}
+
+// placeholderCast returns the cast, if any, for the bind placeholder of
+// a function argument. The cast for a polymorphic argument is a "%s"
+// verb for the cast that is determined when called (see polymorphicCast).
+func placeholderCast(f m.PgFunctionMetadata, col m.PgColumnMetadata) string {
+	if m.IsPolymorphic(col.TypeName) {
+		return "%s"
+	}
+	if f.Overloaded {
+		return "::" + col.DataType
+	}
+	return ""
+}
+
+// optionalArguments splits the input arguments of a function into the
+// required arguments and the trailing optional arguments, those having a
+// default value. As the optional arguments are passed using named
+// notation they also need to be named. Procedures, and functions having
+// a VARIADIC argument, are called with all of their arguments.
+func optionalArguments(f m.PgFunctionMetadata) (required, optional []m.PgColumnMetadata) {
+
+	if f.ObjKind == "p" {
+		return f.CallingArguments, nil
+	}
+
+	k := len(f.CallingArguments)
+	if k > 0 && f.CallingArguments[k-1].ArgMode == "v" {
+		return f.CallingArguments, nil
+	}
+	for k > 0 && f.CallingArguments[k-1].Default != "" && m.ArgIsNamed(f, f.CallingArguments[k-1]) {
+		k--
+	}
+	return f.CallingArguments[:k], f.CallingArguments[k:]
+}
+
+// optionsStructName returns the name of the struct for the optional
+// arguments of a function
+func optionsStructName(f m.PgFunctionMetadata) string {
+	return f.FuncName + "Options"
+}
+
+// optionFieldName returns the options struct field name for an optional
+// function argument. Any "p_" parameter prefix is not included.
+func optionFieldName(col m.PgColumnMetadata) string {
+	name := strings.TrimPrefix(strings.TrimLeft(col.ColumnName, "_"), "p_")
+	if name == "" {
+		name = col.ColumnName
+	}
+	return u.ToUpperCamelCase(name)
+}
+
+// genFunctionOptions generates the struct for the optional arguments of
+// a function. The fields are pointers so that the database default is
+// used for any argument that is not set.
+func genFunctionOptions(args cArgs, f m.PgFunctionMetadata, cb *u.LineBuf) (err error) {
+
+	_, optional := optionalArguments(f)
+	if len(optional) == 0 {
+		return
+	}
+
+	name := optionsStructName(f)
+	cb.Append(fmt.Sprintf("// %s contains the optional arguments for %s. The database", name, f.FuncName))
+	cb.Append("// default is used for any argument that is nil.")
+	cb.Append(fmt.Sprintf("type %s%s struct {", name, typeParamDecl(m.TypeParams(optional))))
+	for _, col := range optional {
+		col.IsRequired = true
+		var varType string
+		varType, err = m.TranslateColumnType(col)
+		if err != nil {
+			err = fmt.Errorf("genFunctionOptions - %s: %s", col.ColumnName, err)
+			return
+		}
+		cb.Append(fmt.Sprintf("\t%s *%s // default: %s", optionFieldName(col), varType, strings.Join(strings.Fields(col.Default), " ")))
+	}
+	cb.Append("}")
+	cb.Append("")
+	return
+}
+
+// appendOptionalArgs appends the building of the call arguments, and of
+// the placeholders for them, for a function having optional arguments
+func appendOptionalArgs(f m.PgFunctionMetadata, required, optional []m.PgColumnMetadata, cb *u.LineBuf) {
+
+	if len(required) == 0 {
+		cb.Append("\tvar args []interface{}")
+		cb.Append("\tvar params []string")
+	} else {
+		var ph []string
+		cb.Append("\targs := []interface{}{")
+		for i, col := range required {
+			cb.Append(fmt.Sprintf("\t\t%s,", callArg(col)))
+			if m.IsPolymorphic(col.TypeName) {
+				ph = append(ph, fmt.Sprintf("%q + %s", fmt.Sprintf("$%d", i+1), polymorphicCast(col)))
+			} else {
+				ph = append(ph, fmt.Sprintf("%q", callPlaceholder(f, col, i+1)))
+			}
+		}
+		cb.Append("\t}")
+		cb.Append(fmt.Sprintf("\tparams := []string{%s}", strings.Join(ph, ", ")))
+	}
+
+	for _, col := range optional {
+		field := optionFieldName(col)
+		cb.Append(fmt.Sprintf("\tif opts.%s != nil {", field))
+		cb.Append(fmt.Sprintf("\t\targs = append(args, *opts.%s)", field))
+		format := fmt.Sprintf("%q, len(args)", u.QuoteIdent(col.ColumnName)+" => $%d"+placeholderCast(f, col))
+		if m.IsPolymorphic(col.TypeName) {
+			format += ", " + polymorphicCast(col)
+		}
+		cb.Append(fmt.Sprintf("\t\tparams = append(params, fmt.Sprintf(%s))", format))
+		cb.Append("\t}")
+	}
+	cb.Append("")
+}
+
+// fieldScanList returns the scan destinations for the fields, of the
+// dest struct variable, for the result columns of a function result
+func fieldScanList(cols []m.PgColumnMetadata, dest string) string {
+	var ary []string
+	for _, col := range cols {
+		ary = append(ary, scanDest(dest+"."+u.ToUpperCamelCase(col.ColumnName), col))
+	}
+	return strings.Join(ary, ", ")
+}
+
+// scanDest returns the scan destination for a result column. Polymorphic
+// array results are scanned through the text of their elements (see
+// polymorphicArraySupport) as pq.Array only supports a few element types.
+func scanDest(dest string, col m.PgColumnMetadata) string {
+	if m.IsPolymorphicArray(col.TypeName) {
+		return fmt.Sprintf("anyArray(%s)", dest)
+	}
+	return dest
+}
+
+// argList returns the parenthesized argument list for calling a function
+func argList(s string) string {
+	if s == "" {
+		return "()"
+	}
+	return fmt.Sprintf("( %s )", s)
+}
+
+// appendCall appends a database call with the function parameters as
+// the call arguments
+func appendCall(prefix string, cols []m.PgColumnMetadata, suffix string, cb *u.LineBuf) {
+
+	if len(cols) == 0 {
+		cb.Append(prefix + suffix)
+		return
+	}
+
+	cb.Append(prefix + ",")
+	for _, col := range cols {
+		cb.Append(fmt.Sprintf("\t\t%s,", callArg(col)))
+	}
+	cb.Append("\t" + suffix)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	m "github.com/gsiems/pg2go/meta"
+)
+
+// TestParamName checks that the argument names that would shadow the
+// generated variables, packages, or keywords are renamed
+func TestParamName(t *testing.T) {
+
+	tests := []struct {
+		argName string
+		want    string
+	}{
+		{"p_customer_id", "pCustomerID"},
+		{"ctx", "ctxArg"},
+		{"pq", "pqArg"},
+		{"sql", "sqlArg"},
+		{"pgtype", "pgtypeArg"},
+		{"type", "typeArg"},
+		{"pg_cast", "pgCastArg"},
+		{"scan_fields", "scanFieldsArg"},
+		{"insert_stmt", "insertStmtArg"},
+		{"scan_record", "scanRecordArg"},
+		{"value_record", "valueRecordArg"},
+		{"any_array", "anyArrayArg"},
+		{"fetch_cursor", "fetchCursorArg"},
+		{"begin_cursor_tx", "beginCursorTxArg"},
+		{"is_null_value", "isNullValueArg"},
+		{"pg_type_names", "pgTypeNamesArg"},
+		{"domain_string", "domainStringArg"},
+	}
+
+	for _, tt := range tests {
+		got := paramName(m.PgColumnMetadata{ColumnName: tt.argName})
+		if got != tt.want {
+			t.Errorf("paramName(%q) = %q, expected %q", tt.argName, got, tt.want)
+		}
+	}
+}
+
+// TestCursorFieldNames checks that the refcursor result fields are named
+// for the declared cursor names, or the OUT refcursor arguments, before
+// falling back to the row structs
+func TestCursorFieldNames(t *testing.T) {
+
+	rows := map[string]rowStruct{
+		"app.customer": {StructName: "Customer"},
+		"app.orders":   {StructName: "Orders"},
+	}
+	cursors := []string{"app.customer", "app.orders", "app.orders"}
+	outArgs := []m.PgColumnMetadata{{ColumnName: "p_customer"}, {ColumnName: "p_open"}, {ColumnName: "p_closed"}}
+
+	tests := []struct {
+		name string
+		f    m.PgFunctionMetadata
+		want []string
+	}{
+		{"row structs", m.PgFunctionMetadata{ReturnsSet: true, Cursors: cursors}, []string{"Customer", "Orders2", "Orders3"}},
+		{"declared names", m.PgFunctionMetadata{ReturnsSet: true, Cursors: cursors, CursorNames: []string{"", "open_orders", "closed_orders"}}, []string{"Customer", "OpenOrders", "ClosedOrders"}},
+		{"OUT arguments", m.PgFunctionMetadata{Cursors: cursors, ResultColumns: outArgs}, []string{"PCustomer", "POpen", "PClosed"}},
+	}
+
+	for _, tt := range tests {
+		fields, err := cursorFields(tt.f, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range fields {
+			got = append(got, c.FieldName)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+}